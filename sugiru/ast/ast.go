@@ -51,11 +51,13 @@ func (p *Program) String() string {
 	return out.String()
 }
 
-// LetStatement statement in the form: "let <IDENTIFIER> = <EXPRESSION>"
+// LetStatement statement in the form: "let <IDENTIFIER> = <EXPRESSION>",
+// or "const <IDENTIFIER> = <EXPRESSION>" for a binding which can't be reassigned
 type LetStatement struct {
-	Token token.Token // token.LET
-	Name  *Identifier
-	Value Expression
+	Token    token.Token // token.LET or token.CONST
+	Name     *Identifier
+	Value    Expression
+	Constant bool // Declared with const
 }
 
 // LetStatement implements Statement
@@ -77,6 +79,145 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// MultiLetStatement declares several bindings at once, in the form
+// "let <IDENTIFIER> = <EXPRESSION>, ..." ( or const in place of let ).
+// The bindings are made in order, so a value can refer to the names before it.
+type MultiLetStatement struct {
+	Token token.Token     // token.LET or token.CONST
+	Lets  []*LetStatement // Each binding, sharing the token of the statement
+}
+
+// MultiLetStatement implements Statement
+func (ms *MultiLetStatement) statementNode() {}
+func (ms *MultiLetStatement) TokenLiteral() string {
+	return ms.Token.Literal
+}
+func (ms *MultiLetStatement) String() string {
+	var out bytes.Buffer
+
+	var bindings []string
+	for _, let := range ms.Lets {
+		binding := let.Name.String() + " = "
+		if let.Value != nil {
+			binding += let.Value.String()
+		}
+		bindings = append(bindings, binding)
+	}
+
+	out.WriteString(ms.TokenLiteral() + " " + strings.Join(bindings, ", ") + ";")
+
+	return out.String()
+}
+
+// DestructureStatement binds each element of an array to a name, in the form
+// "let [<IDENTIFIER>, ...] = <EXPRESSION>" ( or const in place of let )
+type DestructureStatement struct {
+	Token    token.Token // token.LET or token.CONST
+	Names    []*Identifier
+	Value    Expression
+	Constant bool // Declared with const
+}
+
+// DestructureStatement implements Statement
+func (ds *DestructureStatement) statementNode() {}
+func (ds *DestructureStatement) TokenLiteral() string {
+	return ds.Token.Literal
+}
+func (ds *DestructureStatement) String() string {
+	var out bytes.Buffer
+
+	var names []string
+	for _, name := range ds.Names {
+		names = append(names, name.String())
+	}
+
+	out.WriteString(ds.TokenLiteral() + " [" + strings.Join(names, ", ") + "] = ")
+
+	if ds.Value != nil {
+		out.WriteString(ds.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// UpdateExpression increments or decrements an integer binding in place,
+// in the form "++x" or "--x", or "x++" and "x--" when it isn't Prefix
+type UpdateExpression struct {
+	Token    token.Token // The ++ or -- token
+	Operator string
+	Name     *Identifier
+	Prefix   bool // Whether the operator comes before the name
+}
+
+func (ue *UpdateExpression) expressionNode()      {}
+func (ue *UpdateExpression) TokenLiteral() string { return ue.Token.Literal }
+func (ue *UpdateExpression) String() string {
+	if ue.Prefix {
+		return "(" + ue.Operator + ue.Name.String() + ")"
+	}
+	return "(" + ue.Name.String() + ue.Operator + ")"
+}
+
+// AssignExpression rebinds an existing name: "<IDENTIFIER> = <EXPRESSION>",
+// evaluating to the assigned value
+type AssignExpression struct {
+	Token token.Token // The = token
+	Name  *Identifier
+	Value Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(" + ae.Name.String() + " = ")
+
+	if ae.Value != nil {
+		out.WriteString(ae.Value.String())
+	}
+
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// IndexAssignExpression sets an element of an array or hash,
+// in the form "<EXPRESSION>[<EXPRESSION>] = <EXPRESSION>". A compound
+// assignment like `h[k] += v` keeps its Operator, so the container and
+// index are only evaluated once, and the Value is the right operand
+type IndexAssignExpression struct {
+	Token    token.Token // The = token, or the compound one
+	Target   *IndexExpression
+	Operator string // The operator of a compound assignment, empty otherwise
+	Value    Expression
+}
+
+func (ia *IndexAssignExpression) expressionNode()      {}
+func (ia *IndexAssignExpression) TokenLiteral() string { return ia.Token.Literal }
+func (ia *IndexAssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(" + ia.Target.String() + " = ")
+
+	// Printed like the desugared `h[k] = h[k] + v`, the same as a name
+	if ia.Operator != "" {
+		out.WriteString("(" + ia.Target.String() + " " + ia.Operator + " ")
+		if ia.Value != nil {
+			out.WriteString(ia.Value.String())
+		}
+		out.WriteString(")")
+	} else if ia.Value != nil {
+		out.WriteString(ia.Value.String())
+	}
+
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // Identifier a name that is used to identify some value, an EXPRESSION type
 type Identifier struct {
 	Token token.Token // token.IDENT
@@ -95,7 +236,7 @@ func (i *Identifier) String() string {
 // ReturnStatement statement in the form: "return <EXPRESSION>"
 type ReturnStatement struct {
 	Token       token.Token // token.RETURN
-	ReturnValue Expression  // The return value
+	ReturnValue Expression  // The return value, nil for a bare `return`
 }
 
 func (rs *ReturnStatement) statementNode() {}
@@ -106,8 +247,8 @@ func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(rs.TokenLiteral())
-	out.WriteString(" ")
 	if rs.ReturnValue != nil {
+		out.WriteString(" ")
 		out.WriteString(rs.ReturnValue.String())
 	}
 	out.WriteString(";")
@@ -146,6 +287,29 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// FloatLiteral is an expression node
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+// StringLiteral is an expression node
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return `"` + escaper.Replace(sl.Value) + `"` }
+
+// escaper writes the characters the lexer decodes back as escape sequences
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
 // PrefixExpression is an expression node
 type PrefixExpression struct {
 	Token    token.Token
@@ -189,6 +353,15 @@ func (oe *InfixExpression) String() string {
 	return out.String()
 }
 
+// NullLiteral the `null` keyword
+type NullLiteral struct {
+	Token token.Token // The null token
+}
+
+func (nl *NullLiteral) expressionNode()      {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) String() string       { return nl.Token.Literal }
+
 // Boolean implements ExpressionNode
 type Boolean struct {
 	Token token.Token
@@ -211,19 +384,94 @@ func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("if")
+	out.WriteString("if (")
 	out.WriteString(ie.Condition.String())
-	out.WriteString(" ")
+	out.WriteString(") ")
 	out.WriteString(ie.Then.String())
 
 	if ie.Else != nil {
-		out.WriteString("else ")
+		out.WriteString(" else ")
 		out.WriteString(ie.Else.String())
 	}
 
 	return out.String()
 }
 
+// TernaryExpression `<EXPRESSION> ? <EXPRESSION> : <EXPRESSION>`
+type TernaryExpression struct {
+	Token     token.Token // The '?' token
+	Condition Expression
+	Then      Expression
+	Else      Expression
+}
+
+func (te *TernaryExpression) expressionNode()      {}
+func (te *TernaryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TernaryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(te.Condition.String())
+	out.WriteString(" ? ")
+	out.WriteString(te.Then.String())
+	out.WriteString(" : ")
+	out.WriteString(te.Else.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// ForStatement a C style loop in the form:
+// "for (<STATEMENT>; <EXPRESSION>; <STATEMENT>) <BLOCK>", every clause is optional
+type ForStatement struct {
+	Token     token.Token // The for token
+	Init      Statement   // Run once before the loop
+	Condition Expression  // Checked before each iteration, looping forever when missing
+	Post      Statement   // Run after each iteration
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Post != nil {
+		out.WriteString(strings.TrimSuffix(fs.Post.String(), ";"))
+	}
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// BreakStatement leaves the innermost loop: "break"
+type BreakStatement struct {
+	Token token.Token // The break token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) String() string       { return "break;" }
+
+// ContinueStatement skips to the next iteration of the innermost loop: "continue"
+type ContinueStatement struct {
+	Token token.Token // The continue token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) String() string       { return "continue;" }
+
 type BlockStatement struct {
 	Token      token.Token // The { token
 	Statements []Statement // Nested statements
@@ -234,16 +482,27 @@ func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
+	out.WriteString("{ ")
 	for _, stmt := range bs.Statements {
 		out.WriteString(stmt.String())
+
+		// Expression statements don't carry their own semicolon,
+		// without one the statements would run into each other
+		if _, ok := stmt.(*ExpressionStatement); ok {
+			out.WriteString(";")
+		}
+		out.WriteString(" ")
 	}
+	out.WriteString("}")
 
 	return out.String()
 }
 
 type FunctionLiteral struct {
 	Token      token.Token     // fn token
+	Name       string          // The name the function is bound to, if any
 	Parameters []*Identifier   // Parameters passed it
+	Variadic   bool            // The last parameter collects the remaining arguments into an array
 	Body       *BlockStatement // Statements to execute
 }
 
@@ -257,13 +516,15 @@ func (fl *FunctionLiteral) String() string {
 	for _, p := range fl.Parameters {
 		params = append(params, p.String())
 	}
+	if fl.Variadic {
+		params[len(params)-1] = "..." + params[len(params)-1]
+	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
-	out.WriteString("\n}")
 
 	return out.String()
 }
@@ -293,3 +554,91 @@ func (ce *CallExpression) String() string {
 
 	return out.String()
 }
+
+// SpreadExpression `...<EXPRESSION>` passes the elements of an array as
+// separate arguments, it only appears among the arguments of a call
+type SpreadExpression struct {
+	Token token.Token // The '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
+// ArrayLiteral `[<EXPRESSION>, <EXPRESSION>, ...]`
+type ArrayLiteral struct {
+	Token    token.Token // The '[' token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	var elements []string
+
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// IndexExpression `<EXPRESSION>[<EXPRESSION>]`
+type IndexExpression struct {
+	Token token.Token // The '[' token
+	Left  Expression  // The value being indexed
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// HashLiteral `{<EXPRESSION>: <EXPRESSION>, ...}`
+type HashLiteral struct {
+	Token token.Token // The '{' token
+	Pairs []HashLiteralPair
+}
+
+// HashLiteralPair is a single `key: value` in a hash literal
+type HashLiteralPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	var pairs []string
+
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
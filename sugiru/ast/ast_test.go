@@ -24,3 +24,13 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestStringLiteralEscapes(t *testing.T) {
+	str := &StringLiteral{
+		Token: token.Token{Type: token.STRING, Literal: "say \"hi\"\n\\"},
+		Value: "say \"hi\"\n\\",
+	}
+	if str.String() != `"say \"hi\"\n\\"` {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+}
@@ -0,0 +1,256 @@
+package ast
+
+import (
+	"encoding/json"
+	"sugiru/token"
+)
+
+// ToJSON serializes the program into JSON. Every node becomes an object
+// with a "type" field naming the node ( e.g. "InfixExpression" ), the
+// literal and position of its token, and a field for each of its children.
+// Missing children are null.
+func ToJSON(program *Program) ([]byte, error) {
+	return json.Marshal(nodeToJSON(program))
+}
+
+// jsonObject is the JSON representation of a single node
+type jsonObject map[string]interface{}
+
+func nodeToJSON(node Node) interface{} {
+	switch node := node.(type) {
+	case *Program:
+		return jsonObject{
+			"type":       "Program",
+			"statements": statementsToJSON(node.Statements),
+		}
+
+	case *LetStatement:
+		obj := tokenJSON("LetStatement", node.Token)
+		obj["name"] = nodeToJSON(node.Name)
+		obj["value"] = expressionToJSON(node.Value)
+		obj["constant"] = node.Constant
+		return obj
+
+	case *MultiLetStatement:
+		lets := []interface{}{}
+		for _, let := range node.Lets {
+			lets = append(lets, nodeToJSON(let))
+		}
+
+		obj := tokenJSON("MultiLetStatement", node.Token)
+		obj["lets"] = lets
+		return obj
+
+	case *DestructureStatement:
+		names := []interface{}{}
+		for _, name := range node.Names {
+			names = append(names, nodeToJSON(name))
+		}
+
+		obj := tokenJSON("DestructureStatement", node.Token)
+		obj["names"] = names
+		obj["value"] = expressionToJSON(node.Value)
+		obj["constant"] = node.Constant
+		return obj
+
+	case *UpdateExpression:
+		obj := tokenJSON("UpdateExpression", node.Token)
+		obj["operator"] = node.Operator
+		obj["name"] = nodeToJSON(node.Name)
+		obj["prefix"] = node.Prefix
+		return obj
+
+	case *AssignExpression:
+		obj := tokenJSON("AssignExpression", node.Token)
+		obj["name"] = nodeToJSON(node.Name)
+		obj["value"] = expressionToJSON(node.Value)
+		return obj
+
+	case *IndexAssignExpression:
+		obj := tokenJSON("IndexAssignExpression", node.Token)
+		obj["target"] = nodeToJSON(node.Target)
+		obj["operator"] = node.Operator
+		obj["value"] = expressionToJSON(node.Value)
+		return obj
+
+	case *ReturnStatement:
+		obj := tokenJSON("ReturnStatement", node.Token)
+		obj["returnValue"] = expressionToJSON(node.ReturnValue)
+		return obj
+
+	case *ExpressionStatement:
+		obj := tokenJSON("ExpressionStatement", node.Token)
+		obj["expression"] = expressionToJSON(node.Expression)
+		return obj
+
+	case *ForStatement:
+		obj := tokenJSON("ForStatement", node.Token)
+		obj["init"] = statementToJSON(node.Init)
+		obj["condition"] = expressionToJSON(node.Condition)
+		obj["post"] = statementToJSON(node.Post)
+		obj["body"] = nodeToJSON(node.Body)
+		return obj
+
+	case *BreakStatement:
+		return tokenJSON("BreakStatement", node.Token)
+
+	case *ContinueStatement:
+		return tokenJSON("ContinueStatement", node.Token)
+
+	case *BlockStatement:
+		// A missing else block arrives here as a nil pointer
+		if node == nil {
+			return nil
+		}
+		obj := tokenJSON("BlockStatement", node.Token)
+		obj["statements"] = statementsToJSON(node.Statements)
+		return obj
+
+	case *Identifier:
+		obj := tokenJSON("Identifier", node.Token)
+		obj["value"] = node.Value
+		return obj
+
+	case *IntegerLiteral:
+		obj := tokenJSON("IntegerLiteral", node.Token)
+		obj["value"] = node.Value
+		return obj
+
+	case *FloatLiteral:
+		obj := tokenJSON("FloatLiteral", node.Token)
+		obj["value"] = node.Value
+		return obj
+
+	case *StringLiteral:
+		obj := tokenJSON("StringLiteral", node.Token)
+		obj["value"] = node.Value
+		return obj
+
+	case *NullLiteral:
+		return tokenJSON("NullLiteral", node.Token)
+
+	case *Boolean:
+		obj := tokenJSON("Boolean", node.Token)
+		obj["value"] = node.Value
+		return obj
+
+	case *PrefixExpression:
+		obj := tokenJSON("PrefixExpression", node.Token)
+		obj["operator"] = node.Operator
+		obj["right"] = expressionToJSON(node.Right)
+		return obj
+
+	case *InfixExpression:
+		obj := tokenJSON("InfixExpression", node.Token)
+		obj["left"] = expressionToJSON(node.Left)
+		obj["operator"] = node.Operator
+		obj["right"] = expressionToJSON(node.Right)
+		return obj
+
+	case *IfExpression:
+		obj := tokenJSON("IfExpression", node.Token)
+		obj["condition"] = expressionToJSON(node.Condition)
+		obj["then"] = nodeToJSON(node.Then)
+		obj["else"] = nodeToJSON(node.Else)
+		return obj
+
+	case *TernaryExpression:
+		obj := tokenJSON("TernaryExpression", node.Token)
+		obj["condition"] = expressionToJSON(node.Condition)
+		obj["then"] = expressionToJSON(node.Then)
+		obj["else"] = expressionToJSON(node.Else)
+		return obj
+
+	case *FunctionLiteral:
+		params := []interface{}{}
+		for _, p := range node.Parameters {
+			params = append(params, nodeToJSON(p))
+		}
+
+		obj := tokenJSON("FunctionLiteral", node.Token)
+		obj["name"] = node.Name
+		obj["parameters"] = params
+		obj["variadic"] = node.Variadic
+		obj["body"] = nodeToJSON(node.Body)
+		return obj
+
+	case *CallExpression:
+		obj := tokenJSON("CallExpression", node.Token)
+		obj["function"] = expressionToJSON(node.Function)
+		obj["arguments"] = expressionsToJSON(node.Arguments)
+		return obj
+
+	case *SpreadExpression:
+		obj := tokenJSON("SpreadExpression", node.Token)
+		obj["value"] = expressionToJSON(node.Value)
+		return obj
+
+	case *ArrayLiteral:
+		obj := tokenJSON("ArrayLiteral", node.Token)
+		obj["elements"] = expressionsToJSON(node.Elements)
+		return obj
+
+	case *IndexExpression:
+		obj := tokenJSON("IndexExpression", node.Token)
+		obj["left"] = expressionToJSON(node.Left)
+		obj["index"] = expressionToJSON(node.Index)
+		return obj
+
+	case *HashLiteral:
+		pairs := []interface{}{}
+		for _, pair := range node.Pairs {
+			pairs = append(pairs, jsonObject{
+				"key":   expressionToJSON(pair.Key),
+				"value": expressionToJSON(pair.Value),
+			})
+		}
+
+		obj := tokenJSON("HashLiteral", node.Token)
+		obj["pairs"] = pairs
+		return obj
+	}
+
+	return nil
+}
+
+// tokenJSON starts the JSON object of a node with its type and token
+func tokenJSON(nodeType string, tok token.Token) jsonObject {
+	return jsonObject{
+		"type":   nodeType,
+		"token":  tok.Literal,
+		"line":   tok.Line,
+		"column": tok.Column,
+	}
+}
+
+// expressionToJSON converts the expression, a missing expression becomes null
+func expressionToJSON(exp Expression) interface{} {
+	if exp == nil {
+		return nil
+	}
+	return nodeToJSON(exp)
+}
+
+// statementToJSON converts the statement, a missing statement becomes null
+func statementToJSON(stmt Statement) interface{} {
+	if stmt == nil {
+		return nil
+	}
+	return nodeToJSON(stmt)
+}
+
+func expressionsToJSON(exps []Expression) []interface{} {
+	list := []interface{}{}
+	for _, exp := range exps {
+		list = append(list, expressionToJSON(exp))
+	}
+	return list
+}
+
+func statementsToJSON(stmts []Statement) []interface{} {
+	list := []interface{}{}
+	for _, stmt := range stmts {
+		list = append(list, nodeToJSON(stmt))
+	}
+	return list
+}
@@ -0,0 +1,63 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"sugiru/ast"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	program := parse(t, `let x = 1 + 2;
+if (x) { "yes" }`)
+
+	data, err := ast.ToJSON(program)
+	if err != nil {
+		t.Fatalf("ToJSON returned an error: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, data)
+	}
+
+	if decoded["type"] != "Program" {
+		t.Fatalf("root type wrong. got=%v", decoded["type"])
+	}
+	statements := decoded["statements"].([]interface{})
+	if len(statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(statements))
+	}
+
+	let := statements[0].(map[string]interface{})
+	if let["type"] != "LetStatement" || let["token"] != "let" {
+		t.Errorf("let statement wrong. got=%v", let)
+	}
+	if name := let["name"].(map[string]interface{}); name["value"] != "x" {
+		t.Errorf("let name wrong. got=%v", name)
+	}
+
+	value := let["value"].(map[string]interface{})
+	if value["type"] != "InfixExpression" || value["operator"] != "+" {
+		t.Errorf("let value wrong. got=%v", value)
+	}
+	right := value["right"].(map[string]interface{})
+	if right["type"] != "IntegerLiteral" || right["value"] != 2.0 {
+		t.Errorf("infix right wrong. got=%v", right)
+	}
+	if right["line"] != 1.0 || right["column"] != 13.0 {
+		t.Errorf("infix right position wrong. got=%v:%v", right["line"], right["column"])
+	}
+
+	ifExp := statements[1].(map[string]interface{})["expression"].(map[string]interface{})
+	if ifExp["type"] != "IfExpression" {
+		t.Fatalf("expression type wrong. got=%v", ifExp["type"])
+	}
+	if ifExp["else"] != nil {
+		t.Errorf("missing else is not null. got=%v", ifExp["else"])
+	}
+	then := ifExp["then"].(map[string]interface{})["statements"].([]interface{})
+	str := then[0].(map[string]interface{})["expression"].(map[string]interface{})
+	if str["type"] != "StringLiteral" || str["value"] != "yes" {
+		t.Errorf("string literal wrong. got=%v", str)
+	}
+}
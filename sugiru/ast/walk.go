@@ -0,0 +1,129 @@
+package ast
+
+// Walk traverses the tree rooted at node depth first, calling visit on
+// every node before its children. When visit returns false the children
+// of that node are skipped. Missing children ( e.g. an if without an
+// else ) are never visited.
+func Walk(node Node, visit func(Node) bool) {
+	if !visit(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, stmt := range node.Statements {
+			Walk(stmt, visit)
+		}
+
+	case *LetStatement:
+		Walk(node.Name, visit)
+		walkExpression(node.Value, visit)
+
+	case *MultiLetStatement:
+		for _, let := range node.Lets {
+			Walk(let, visit)
+		}
+
+	case *DestructureStatement:
+		for _, name := range node.Names {
+			Walk(name, visit)
+		}
+		walkExpression(node.Value, visit)
+
+	case *UpdateExpression:
+		Walk(node.Name, visit)
+
+	case *AssignExpression:
+		Walk(node.Name, visit)
+		walkExpression(node.Value, visit)
+
+	case *IndexAssignExpression:
+		Walk(node.Target, visit)
+		walkExpression(node.Value, visit)
+
+	case *ReturnStatement:
+		walkExpression(node.ReturnValue, visit)
+
+	case *ExpressionStatement:
+		walkExpression(node.Expression, visit)
+
+	case *ForStatement:
+		if node.Init != nil {
+			Walk(node.Init, visit)
+		}
+		walkExpression(node.Condition, visit)
+		if node.Post != nil {
+			Walk(node.Post, visit)
+		}
+		if node.Body != nil {
+			Walk(node.Body, visit)
+		}
+
+	case *BlockStatement:
+		for _, stmt := range node.Statements {
+			Walk(stmt, visit)
+		}
+
+	case *PrefixExpression:
+		walkExpression(node.Right, visit)
+
+	case *InfixExpression:
+		walkExpression(node.Left, visit)
+		walkExpression(node.Right, visit)
+
+	case *IfExpression:
+		walkExpression(node.Condition, visit)
+		if node.Then != nil {
+			Walk(node.Then, visit)
+		}
+		if node.Else != nil {
+			Walk(node.Else, visit)
+		}
+
+	case *TernaryExpression:
+		walkExpression(node.Condition, visit)
+		walkExpression(node.Then, visit)
+		walkExpression(node.Else, visit)
+
+	case *FunctionLiteral:
+		for _, param := range node.Parameters {
+			Walk(param, visit)
+		}
+		if node.Body != nil {
+			Walk(node.Body, visit)
+		}
+
+	case *CallExpression:
+		walkExpression(node.Function, visit)
+		for _, arg := range node.Arguments {
+			walkExpression(arg, visit)
+		}
+
+	case *SpreadExpression:
+		walkExpression(node.Value, visit)
+
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			walkExpression(el, visit)
+		}
+
+	case *IndexExpression:
+		walkExpression(node.Left, visit)
+		walkExpression(node.Index, visit)
+
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			walkExpression(pair.Key, visit)
+			walkExpression(pair.Value, visit)
+		}
+	}
+
+	// Identifiers and literals have no children
+}
+
+// walkExpression walks the expression unless it is missing
+func walkExpression(exp Expression, visit func(Node) bool) {
+	if exp != nil {
+		Walk(exp, visit)
+	}
+}
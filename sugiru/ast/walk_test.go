@@ -0,0 +1,67 @@
+package ast_test
+
+import (
+	"sugiru/ast"
+	"sugiru/lexer"
+	"sugiru/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestWalkCountsIntegerLiterals(t *testing.T) {
+	input := `
+let x = 1 + 2;
+let f = fn(a, b) {
+	if (a > 3) { return [4, 5][0]; } else { b = -6; }
+	a ? 7 : {8: 9}
+};
+f(10, x);
+`
+	program := parse(t, input)
+
+	var values []int64
+	ast.Walk(program, func(node ast.Node) bool {
+		if il, ok := node.(*ast.IntegerLiteral); ok {
+			values = append(values, il.Value)
+		}
+		return true
+	})
+
+	// Nodes are visited in source order
+	expected := []int64{1, 2, 3, 4, 5, 0, 6, 7, 8, 9, 10}
+	if len(values) != len(expected) {
+		t.Fatalf("wrong number of integer literals. want=%d, got=%d (%v)",
+			len(expected), len(values), values)
+	}
+	for i, v := range expected {
+		if values[i] != v {
+			t.Errorf("values[%d] wrong. want=%d, got=%d", i, v, values[i])
+		}
+	}
+}
+
+func TestWalkSkipsSubtree(t *testing.T) {
+	program := parse(t, "let a = 1; let f = fn() { 2 + 3 }; 4;")
+
+	count := 0
+	ast.Walk(program, func(node ast.Node) bool {
+		if _, ok := node.(*ast.IntegerLiteral); ok {
+			count++
+		}
+		// Don't descend into function bodies
+		_, isFunction := node.(*ast.FunctionLiteral)
+		return !isFunction
+	})
+
+	if count != 2 {
+		t.Errorf("wrong number of integer literals outside functions. want=2, got=%d", count)
+	}
+}
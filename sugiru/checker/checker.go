@@ -0,0 +1,198 @@
+package checker
+
+import (
+	"fmt"
+	"sugiru/ast"
+	"sugiru/token"
+)
+
+// Diagnostic is a warning about a program which is still valid,
+// but probably doesn't do what was intended
+type Diagnostic struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: warning: %s", d.Line, d.Column, d.Message)
+}
+
+type checker struct {
+	diagnostics []Diagnostic
+
+	// The names declared in each enclosing scope, innermost last. Like
+	// the evaluator, only the program, function bodies and loops open a scope.
+	scopes []map[string]token.Token
+}
+
+// Check analyses the program and returns every diagnostic found, in source order
+func Check(program *ast.Program) []Diagnostic {
+	c := &checker{}
+	c.pushScope()
+	c.checkStatements(program.Statements)
+	c.popScope()
+	return c.diagnostics
+}
+
+func (c *checker) pushScope() {
+	c.scopes = append(c.scopes, map[string]token.Token{})
+}
+
+func (c *checker) popScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// declare records a binding in the current scope, warning
+// when it shadows a binding already made in the same scope
+func (c *checker) declare(name *ast.Identifier) {
+	scope := c.scopes[len(c.scopes)-1]
+
+	// Nothing is ever bound to the throwaway name
+	if name.Value == "_" {
+		return
+	}
+
+	if previous, ok := scope[name.Value]; ok {
+		c.warn(name.Token, "'%s' shadows the binding declared at %d:%d",
+			name.Value, previous.Line, previous.Column)
+	}
+
+	scope[name.Value] = name.Token
+}
+
+func (c *checker) warn(tok token.Token, format string, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{
+		Message: fmt.Sprintf(format, a...),
+		Line:    tok.Line,
+		Column:  tok.Column,
+	})
+}
+
+// checkStatements checks a list of statements which share a block
+func (c *checker) checkStatements(statements []ast.Statement) {
+	for i, stmt := range statements {
+		c.checkNode(stmt)
+
+		// Anything after a return in the same block can never run,
+		// only the first such statement is reported
+		if _, ok := stmt.(*ast.ReturnStatement); ok && i+1 < len(statements) {
+			next := statements[i+1]
+			c.warn(firstToken(next), "unreachable code after return")
+
+			for _, rest := range statements[i+1:] {
+				c.checkNode(rest)
+			}
+			return
+		}
+	}
+}
+
+// checkNode descends into the children of the node
+func (c *checker) checkNode(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		c.checkNode(node.Value)
+		c.declare(node.Name)
+	case *ast.MultiLetStatement:
+		for _, let := range node.Lets {
+			c.checkNode(let)
+		}
+	case *ast.DestructureStatement:
+		c.checkNode(node.Value)
+		for _, name := range node.Names {
+			c.declare(name)
+		}
+	case *ast.AssignExpression:
+		c.checkNode(node.Value)
+	case *ast.IndexAssignExpression:
+		c.checkNode(node.Target)
+		c.checkNode(node.Value)
+	case *ast.ReturnStatement:
+		c.checkNode(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.checkNode(node.Expression)
+	case *ast.ForStatement:
+		// The loop and each iteration of its body get their own scope
+		c.pushScope()
+		if node.Init != nil {
+			c.checkNode(node.Init)
+		}
+		c.checkNode(node.Condition)
+		if node.Post != nil {
+			c.checkNode(node.Post)
+		}
+		c.pushScope()
+		c.checkNode(node.Body)
+		c.popScope()
+		c.popScope()
+	case *ast.BlockStatement:
+		c.checkStatements(node.Statements)
+	case *ast.PrefixExpression:
+		c.checkNode(node.Right)
+	case *ast.InfixExpression:
+		c.checkNode(node.Left)
+		c.checkNode(node.Right)
+	case *ast.IfExpression:
+		c.checkNode(node.Condition)
+		c.checkNode(node.Then)
+		if node.Else != nil {
+			c.checkNode(node.Else)
+		}
+	case *ast.TernaryExpression:
+		c.checkNode(node.Condition)
+		c.checkNode(node.Then)
+		c.checkNode(node.Else)
+	case *ast.FunctionLiteral:
+		c.pushScope()
+		for _, param := range node.Parameters {
+			c.declare(param)
+		}
+		c.checkNode(node.Body)
+		c.popScope()
+	case *ast.CallExpression:
+		c.checkNode(node.Function)
+		for _, arg := range node.Arguments {
+			c.checkNode(arg)
+		}
+	case *ast.SpreadExpression:
+		c.checkNode(node.Value)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			c.checkNode(el)
+		}
+	case *ast.IndexExpression:
+		c.checkNode(node.Left)
+		c.checkNode(node.Index)
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			c.checkNode(pair.Key)
+			c.checkNode(pair.Value)
+		}
+	}
+}
+
+// firstToken returns the token a statement starts at
+func firstToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.MultiLetStatement:
+		return stmt.Token
+	case *ast.DestructureStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.ForStatement:
+		return stmt.Token
+	case *ast.BreakStatement:
+		return stmt.Token
+	case *ast.ContinueStatement:
+		return stmt.Token
+	case *ast.BlockStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
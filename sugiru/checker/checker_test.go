@@ -0,0 +1,80 @@
+package checker
+
+import (
+	"sugiru/lexer"
+	"sugiru/parser"
+	"testing"
+)
+
+func testCheck(t *testing.T, input string) []Diagnostic {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return Check(program)
+}
+
+func TestUnreachableAfterReturn(t *testing.T) {
+	input := `let f = fn(x) {
+	return x;
+	let y = x * 2;
+	y;
+};`
+
+	diagnostics := testCheck(t, input)
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic. got=%d (%v)", len(diagnostics), diagnostics)
+	}
+
+	d := diagnostics[0]
+	if d.Message != "unreachable code after return" {
+		t.Errorf("wrong message. got=%q", d.Message)
+	}
+	if d.Line != 3 || d.Column != 2 {
+		t.Errorf("wrong position. expected=3:2, got=%d:%d", d.Line, d.Column)
+	}
+}
+
+func TestNoDiagnostics(t *testing.T) {
+	input := `let f = fn(x) { if (x > 1) { return x; } return 1; };`
+
+	diagnostics := testCheck(t, input)
+	if len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics. got=%v", diagnostics)
+	}
+}
+
+func TestLetShadowing(t *testing.T) {
+	input := `let x = 1;
+let x = x + 1;
+let f = fn(y) {
+	let x = 3;
+	if (y) { let y = 4; }
+	x
+};`
+
+	diagnostics := testCheck(t, input)
+
+	expected := []Diagnostic{
+		{"'x' shadows the binding declared at 1:5", 2, 5},
+		{"'y' shadows the binding declared at 3:12", 5, 15},
+	}
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("wrong number of diagnostics. want=%d, got=%d (%v)",
+			len(expected), len(diagnostics), diagnostics)
+	}
+	for i, d := range expected {
+		if diagnostics[i] != d {
+			t.Errorf("diagnostics[%d] wrong. want=%v, got=%v", i, d, diagnostics[i])
+		}
+	}
+}
+
+func TestDiscardIsNotShadowed(t *testing.T) {
+	if diagnostics := testCheck(t, "let _ = 1; let _ = 2; let f = fn(_, _) { 1 };"); len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics. got=%v", diagnostics)
+	}
+}
@@ -0,0 +1,1017 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sugiru/ast"
+	"sugiru/lexer"
+	"sugiru/object"
+	"sugiru/parser"
+	"sugiru/token"
+	"sync"
+	"time"
+)
+
+// Output is where `puts` writes to
+var Output io.Writer = os.Stdout
+
+// Sleeper is used by `sleep` to pause, tests swap it to avoid waiting
+var Sleeper = time.Sleep
+
+// unsafeBuiltins are the builtins hidden from a sandboxed environment,
+// as if they were never defined
+var unsafeBuiltins = map[string]bool{
+	"eval": true,
+}
+
+// Clock tells the time for `benchmark` and `now`, tests swap it for a fake clock
+var Clock = time.Now
+
+// random backs `rand` and `randInt`, `seed` reseeds it to make runs reproducible
+var random = &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// lockedRand guards a generator, which isn't safe for concurrent use on its
+// own, so interpreters running at the same time can share it
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
+
+// Seed replaces the generator with one started from seed
+func (l *lockedRand) Seed(seed int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.r = rand.New(rand.NewSource(seed))
+}
+
+// display returns the text `puts` writes for the value, a string is written
+// as is while strings within arrays and hashes are quoted
+func display(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return str.Value
+	}
+	return obj.Inspect()
+}
+
+var builtins = map[string]*object.Builtin{
+	// puts(args...) prints each argument on its own line
+	"puts": {
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(Output, display(arg))
+			}
+			return NULL
+		},
+	},
+	// substr(s, start, length) returns the substring of s beginning at the
+	// rune offset start and spanning at most length runes. A negative start
+	// counts from the end of the string, and both ends are clamped to the
+	// bounds of the string.
+	"substr": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument 1 to `substr` must be STRING, got %s", args[0].Type())
+			}
+			start, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument 2 to `substr` must be INTEGER, got %s", args[1].Type())
+			}
+			length, ok := args[2].(*object.Integer)
+			if !ok {
+				return newError("argument 3 to `substr` must be INTEGER, got %s", args[2].Type())
+			}
+			if length.Value < 0 {
+				return newError("length passed to `substr` must not be negative, got %d", length.Value)
+			}
+
+			runes := []rune(str.Value)
+			size := int64(len(runes))
+
+			// Negative offsets are taken from the end of the string
+			from := start.Value
+			if from < 0 {
+				from += size
+			}
+
+			// Clamp both ends to the string
+			if from < 0 {
+				from = 0
+			}
+			if from > size {
+				from = size
+			}
+			to := size
+			if length.Value < size-from {
+				to = from + length.Value
+			}
+
+			return &object.String{Value: string(runes[from:to])}
+		},
+	},
+	// split(s, sep) splits s around every occurrence of sep, an empty
+	// sep splits s into its individual characters.
+	"split": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument 1 to `split` must be STRING, got %s", args[0].Type())
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument 2 to `split` must be STRING, got %s", args[1].Type())
+			}
+
+			// Splitting nothing gives nothing, rather than a single empty string
+			if str.Value == "" {
+				return &object.Array{Elements: []object.Object{}}
+			}
+
+			parts := strings.Split(str.Value, sep.Value)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	// join(arr, sep) concatenates the strings in arr, with sep between each.
+	"join": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument 1 to `join` must be ARRAY, got %s", args[0].Type())
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument 2 to `join` must be STRING, got %s", args[1].Type())
+			}
+
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return newError("element %d passed to `join` must be STRING, got %s", i, el.Type())
+				}
+				parts[i] = str.Value
+			}
+
+			return &object.String{Value: strings.Join(parts, sep.Value)}
+		},
+	},
+	// int(x) converts a string, float or integer to an integer,
+	// floats are truncated towards zero.
+	"int": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				value, err := strconv.ParseInt(arg.Value, 10, 64)
+				if err != nil {
+					return newError("cannot convert %q to INTEGER", arg.Value)
+				}
+				return &object.Integer{Value: value}
+			default:
+				return newError("argument to `int` must be STRING, FLOAT or INTEGER, got %s", args[0].Type())
+			}
+		},
+	},
+	// str(x) converts any value to a string, the same way it is displayed.
+	"str": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+			return &object.String{Value: display(args[0])}
+		},
+	},
+	// tokenize(source) runs the source through the lexer, returning a hash
+	// with the "type" and "literal" of each token, the EOF token excluded
+	"tokenize": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			source, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `tokenize` must be STRING, got %s", args[0].Type())
+			}
+
+			tokens := []object.Object{}
+			l := lexer.New(source.Value)
+			for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+				tokens = append(tokens, newHash(map[string]object.Object{
+					"type":    &object.String{Value: string(tok.Type)},
+					"literal": &object.String{Value: tok.Literal},
+				}))
+			}
+
+			return &object.Array{Elements: tokens}
+		},
+	},
+	// format(template, values...) replaces each `{}` in the template with the
+	// next value, displayed the same way as by `str`. `{{` and `}}` stand for
+	// a literal `{` and `}`.
+	"format": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+
+			template, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument 1 to `format` must be STRING, got %s", args[0].Type())
+			}
+
+			var out strings.Builder
+			values, placeholders := args[1:], 0
+
+			for i := 0; i < len(template.Value); i++ {
+				ch := template.Value[i]
+
+				var next byte
+				if i+1 < len(template.Value) {
+					next = template.Value[i+1]
+				}
+
+				switch {
+				case ch == '{' && next == '}':
+					if placeholders < len(values) {
+						out.WriteString(display(values[placeholders]))
+					}
+					placeholders++
+					i++
+				case (ch == '{' || ch == '}') && next == ch:
+					out.WriteByte(ch)
+					i++
+				default:
+					out.WriteByte(ch)
+				}
+			}
+
+			if placeholders != len(values) {
+				return newError("format string has %d placeholders, got %d values", placeholders, len(values))
+			}
+
+			return &object.String{Value: out.String()}
+		},
+	},
+	// toString(x) formats the integer x in base 10, toString(x, radix)
+	// formats it in the given radix, which must be within 2 to 36.
+	"toString": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			integer, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument 1 to `toString` must be INTEGER, got %s", args[0].Type())
+			}
+
+			radix := int64(10)
+			if len(args) == 2 {
+				r, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("argument 2 to `toString` must be INTEGER, got %s", args[1].Type())
+				}
+				radix = r.Value
+			}
+			if radix < 2 || radix > 36 {
+				return newError("radix passed to `toString` must be between 2 and 36, got %d", radix)
+			}
+
+			return &object.String{Value: strconv.FormatInt(integer.Value, int(radix))}
+		},
+	},
+	// toFixed(x, digits) formats the number x with exactly digits decimal places.
+	"toFixed": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			var value float64
+			switch arg := args[0].(type) {
+			case *object.Float:
+				value = arg.Value
+			case *object.Integer:
+				value = float64(arg.Value)
+			default:
+				return newError("argument 1 to `toFixed` must be FLOAT or INTEGER, got %s", args[0].Type())
+			}
+
+			digits, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument 2 to `toFixed` must be INTEGER, got %s", args[1].Type())
+			}
+			if digits.Value < 0 {
+				return newError("digits passed to `toFixed` must not be negative, got %d", digits.Value)
+			}
+
+			return &object.String{Value: strconv.FormatFloat(value, 'f', int(digits.Value), 64)}
+		},
+	},
+	// parse(source) returns how the source parses, as the canonical
+	// string of the program, or an error listing every parser error.
+	"parse": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			source, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `parse` must be STRING, got %s", args[0].Type())
+			}
+
+			program, err := parseSource(source.Value)
+			if err != nil {
+				return err
+			}
+
+			return &object.String{Value: program.String()}
+		},
+	},
+	// abs(x) returns the absolute value of x, keeping its type.
+	"abs": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				// The smallest integer has no positive counterpart
+				if arg.Value == math.MinInt64 {
+					return newError("integer overflow in `abs`")
+				}
+				if arg.Value < 0 {
+					return &object.Integer{Value: -arg.Value}
+				}
+				return arg
+			case *object.Float:
+				return &object.Float{Value: math.Abs(arg.Value)}
+			default:
+				return newError("argument to `abs` must be FLOAT or INTEGER, got %s", args[0].Type())
+			}
+		},
+	},
+	"min": extremum("min", func(a, b float64) bool { return a < b }),
+	"max": extremum("max", func(a, b float64) bool { return a > b }),
+	// range(stop), range(start, stop) and range(start, stop, step) return
+	// the integers from start up to but excluding stop. A negative step
+	// counts down instead, so range(3, 0, -1) is [3, 2, 1].
+	"range": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1, 2 or 3", len(args))
+			}
+
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("argument %d to `range` must be INTEGER, got %s", i+1, arg.Type())
+				}
+				bounds[i] = integer.Value
+			}
+
+			start, stop, step := int64(0), bounds[0], int64(1)
+			if len(bounds) > 1 {
+				start, stop = bounds[0], bounds[1]
+			}
+			if len(bounds) > 2 {
+				step = bounds[2]
+			}
+			if step == 0 {
+				return newError("step passed to `range` must not be zero")
+			}
+
+			elements := []object.Object{}
+			for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+				elements = append(elements, &object.Integer{Value: i})
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	// assert(cond) and assert(cond, message) do nothing when cond is truthy,
+	// otherwise they raise an assertion error, stopping the program.
+	"assert": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			message := "assertion failed"
+			if len(args) == 2 {
+				str, ok := args[1].(*object.String)
+				if !ok {
+					return newError("argument 2 to `assert` must be STRING, got %s", args[1].Type())
+				}
+				message += ": " + str.Value
+			}
+
+			if isTruthy(args[0]) {
+				return NULL
+			}
+			return &object.Error{Message: message, Assertion: true}
+		},
+	},
+	// clone(x) returns a shallow copy of the array or hash x, the copy
+	// holds the same elements, so nested arrays and hashes are shared.
+	"clone": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				elements := make([]object.Object, len(arg.Elements))
+				copy(elements, arg.Elements)
+				return &object.Array{Elements: elements}
+			case *object.Hash:
+				pairs := make(map[object.HashKey]object.HashPair, len(arg.Pairs))
+				for key, pair := range arg.Pairs {
+					pairs[key] = pair
+				}
+				return &object.Hash{Pairs: pairs}
+			default:
+				return newError("argument to `clone` must be ARRAY or HASH, got %s", args[0].Type())
+			}
+		},
+	},
+	// Predicates for checking the type of a value at runtime
+	"isInt":      typePredicate("isInt", object.INTEGER_OBJ),
+	"isFloat":    typePredicate("isFloat", object.FLOAT_OBJ),
+	"isString":   typePredicate("isString", object.STRING_OBJ),
+	"isArray":    typePredicate("isArray", object.ARRAY_OBJ),
+	"isHash":     typePredicate("isHash", object.HASH_OBJ),
+	"isFunction": typePredicate("isFunction", object.FUNCTION_OBJ, object.BUILTIN_OBJ),
+	"isNull":     typePredicate("isNull", object.NULL_OBJ),
+	"isBool":     typePredicate("isBool", object.BOOLEAN_OBJ),
+}
+
+// impureBuiltins depend on the world outside of the program, time and
+// randomness, so calling them twice may not give the same result. They
+// are registered along with the other builtins.
+var impureBuiltins = map[string]*object.Builtin{
+	// now() returns the current Unix time in milliseconds.
+	"now": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return &object.Integer{Value: Clock().UnixMilli()}
+		},
+	},
+	// sleep(ms) pauses evaluation for ms milliseconds.
+	"sleep": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			ms, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `sleep` must be INTEGER, got %s", args[0].Type())
+			}
+			if ms.Value < 0 {
+				return newError("duration passed to `sleep` must not be negative, got %d", ms.Value)
+			}
+
+			Sleeper(time.Duration(ms.Value) * time.Millisecond)
+			return NULL
+		},
+	},
+	// rand() returns a float in [0, 1).
+	"rand": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return &object.Float{Value: random.Float64()}
+		},
+	},
+	// randInt(lo, hi) returns an integer in [lo, hi).
+	"randInt": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			lo, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument 1 to `randInt` must be INTEGER, got %s", args[0].Type())
+			}
+			hi, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument 2 to `randInt` must be INTEGER, got %s", args[1].Type())
+			}
+			if lo.Value >= hi.Value {
+				return newError("range passed to `randInt` is empty: [%d, %d)", lo.Value, hi.Value)
+			}
+
+			// The span overflows when the bounds are too far apart
+			span := hi.Value - lo.Value
+			if span <= 0 {
+				return newError("range passed to `randInt` is too large: [%d, %d)", lo.Value, hi.Value)
+			}
+
+			return &object.Integer{Value: lo.Value + random.Int63n(span)}
+		},
+	},
+	// seed(n) reseeds the generator behind `rand` and `randInt`.
+	"seed": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			n, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `seed` must be INTEGER, got %s", args[0].Type())
+			}
+
+			random.Seed(n.Value)
+			return NULL
+		},
+	},
+}
+
+// typePredicate creates a builtin taking one argument which
+// returns whether the argument is of one of the given types
+func typePredicate(name string, types ...object.ObjectType) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `%s`. got=%d, want=1", name, len(args))
+			}
+
+			for _, t := range types {
+				if args[0].Type() == t {
+					return TRUE
+				}
+			}
+			return FALSE
+		},
+	}
+}
+
+// extremum builds `min` and `max`, which take two or more numbers and
+// return the one for which better holds against all the others. The
+// winner is returned as is, so min(1, 2.5) is the integer 1.
+func extremum(name string, better func(a, b float64) bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newError("wrong number of arguments to `%s`. got=%d, want at least 2", name, len(args))
+			}
+
+			var best object.Object
+			for i, arg := range args {
+				if !isNumeric(arg) {
+					return newError("argument %d to `%s` must be FLOAT or INTEGER, got %s", i+1, name, arg.Type())
+				}
+				if best == nil || better(floatValue(arg), floatValue(best)) {
+					best = arg
+				}
+			}
+
+			return best
+		},
+	}
+}
+
+// quantifier builds `all` and `any`, which apply fn to each element of
+// arr and stop as soon as the result's truthiness equals stopOn. Reaching
+// the end of the array returns the opposite of stopOn, so all([]) is true
+// while any([]) is false.
+func quantifier(name string, stopOn bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments to `%s`. got=%d, want=2", name, len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument 1 to `%s` must be ARRAY, got %s", name, args[0].Type())
+			}
+			if !isCallable(args[1]) {
+				return newError("argument 2 to `%s` must be FUNCTION, got %s", name, args[1].Type())
+			}
+
+			for _, el := range arr.Elements {
+				result := applyFunction(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) == stopOn {
+					return nativeBoolToBooleanObject(stopOn)
+				}
+			}
+
+			return nativeBoolToBooleanObject(!stopOn)
+		},
+	}
+}
+
+// These builtins call back into the evaluator, registering them
+// here avoids an initialization cycle through the builtins map
+func init() {
+	for name, builtin := range impureBuiltins {
+		builtins[name] = builtin
+	}
+
+	builtins["eval"] = evalBuiltin
+
+	builtins["all"] = quantifier("all", false)
+	builtins["any"] = quantifier("any", true)
+
+	// is_null is the spelling asked for by scripts written with snake_case
+	// names, it is the very same builtin as isNull so the two can't drift apart
+	builtins["is_null"] = builtins["isNull"]
+
+	// benchmark(fn, iterations) calls the zero argument function fn the given
+	// number of times, returning a hash with the "total" and "average" time
+	// taken per call, both in nanoseconds.
+	builtins["benchmark"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			fn := args[0]
+			switch fn := fn.(type) {
+			case *object.Function:
+				// A variadic function can be called with nothing for its last parameter
+				if n := len(fn.Parameters); n != 0 && !(fn.Variadic && n == 1) {
+					return newError("function passed to `benchmark` must take no arguments, takes %d", len(fn.Parameters))
+				}
+			case *object.Builtin:
+			default:
+				return newError("argument 1 to `benchmark` must be FUNCTION, got %s", args[0].Type())
+			}
+
+			iterations, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument 2 to `benchmark` must be INTEGER, got %s", args[1].Type())
+			}
+			if iterations.Value <= 0 {
+				return newError("iterations passed to `benchmark` must be positive, got %d", iterations.Value)
+			}
+
+			// Only the calls themselves are timed
+			var total time.Duration
+			for i := int64(0); i < iterations.Value; i++ {
+				start := Clock()
+				result := applyFunction(fn, []object.Object{})
+				total += Clock().Sub(start)
+
+				if isError(result) {
+					return result
+				}
+			}
+
+			average := total / time.Duration(iterations.Value)
+
+			return newHash(map[string]object.Object{
+				"total":   &object.Integer{Value: int64(total)},
+				"average": &object.Integer{Value: int64(average)},
+			})
+		},
+	}
+
+	// scan(arr, initial, fn) folds fn over arr like reduce, but returns
+	// every intermediate accumulator, e.g. scan([1, 2, 3], 0, add) is [1, 3, 6].
+	builtins["scan"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument 1 to `scan` must be ARRAY, got %s", args[0].Type())
+			}
+			if !isCallable(args[2]) {
+				return newError("argument 3 to `scan` must be FUNCTION, got %s", args[2].Type())
+			}
+
+			acc := args[1]
+			results := make([]object.Object, 0, len(arr.Elements))
+			for _, el := range arr.Elements {
+				acc = applyFunction(args[2], []object.Object{acc, el})
+				if isError(acc) {
+					return acc
+				}
+				results = append(results, acc)
+			}
+
+			return &object.Array{Elements: results}
+		},
+	}
+
+	// partition(arr, fn) splits arr into [matching, nonMatching] depending on
+	// whether fn(elem) is truthy, keeping the original order within each half.
+	builtins["partition"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument 1 to `partition` must be ARRAY, got %s", args[0].Type())
+			}
+			if !isCallable(args[1]) {
+				return newError("argument 2 to `partition` must be FUNCTION, got %s", args[1].Type())
+			}
+
+			matching := []object.Object{}
+			nonMatching := []object.Object{}
+			for _, el := range arr.Elements {
+				result := applyFunction(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+
+				if isTruthy(result) {
+					matching = append(matching, el)
+				} else {
+					nonMatching = append(nonMatching, el)
+				}
+			}
+
+			return &object.Array{Elements: []object.Object{
+				&object.Array{Elements: matching},
+				&object.Array{Elements: nonMatching},
+			}}
+		},
+	}
+
+	// flatMap(arr, fn) applies fn to each element, where fn returns an array,
+	// and concatenates the resulting arrays into a single flat array.
+	builtins["flatMap"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument 1 to `flatMap` must be ARRAY, got %s", args[0].Type())
+			}
+			if !isCallable(args[1]) {
+				return newError("argument 2 to `flatMap` must be FUNCTION, got %s", args[1].Type())
+			}
+
+			results := []object.Object{}
+			for _, el := range arr.Elements {
+				result := applyFunction(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+
+				inner, ok := result.(*object.Array)
+				if !ok {
+					return newError("function passed to `flatMap` must return ARRAY, got %s", result.Type())
+				}
+				results = append(results, inner.Elements...)
+			}
+
+			return &object.Array{Elements: results}
+		},
+	}
+
+	// map(arr, fn) returns a new array holding fn(elem) for every element
+	builtins["map"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument 1 to `map` must be ARRAY, got %s", args[0].Type())
+			}
+			if !isCallable(args[1]) {
+				return newError("argument 2 to `map` must be FUNCTION, got %s", args[1].Type())
+			}
+
+			results := make([]object.Object, 0, len(arr.Elements))
+			for _, el := range arr.Elements {
+				result := applyFunction(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				results = append(results, result)
+			}
+
+			return &object.Array{Elements: results}
+		},
+	}
+
+	// reduce(arr, initial, fn) folds fn over arr from the left, calling
+	// fn(acc, elem) for every element and returning the final accumulator.
+	builtins["reduce"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument 1 to `reduce` must be ARRAY, got %s", args[0].Type())
+			}
+			if !isCallable(args[2]) {
+				return newError("argument 3 to `reduce` must be FUNCTION, got %s", args[2].Type())
+			}
+
+			acc := args[1]
+			for _, el := range arr.Elements {
+				acc = applyFunction(args[2], []object.Object{acc, el})
+				if isError(acc) {
+					return acc
+				}
+			}
+
+			return acc
+		},
+	}
+
+	// filter(arr, fn) returns the elements for which fn(elem) is truthy,
+	// following the same rules as the condition of an if
+	builtins["filter"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument 1 to `filter` must be ARRAY, got %s", args[0].Type())
+			}
+			if !isCallable(args[1]) {
+				return newError("argument 2 to `filter` must be FUNCTION, got %s", args[1].Type())
+			}
+
+			results := []object.Object{}
+			for _, el := range arr.Elements {
+				result := applyFunction(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					results = append(results, el)
+				}
+			}
+
+			return &object.Array{Elements: results}
+		},
+	}
+
+	// apply(fn, args) calls fn with the elements of the array args as its
+	// arguments, apply(f, [a, b]) is the same as f(a, b)
+	builtins["apply"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if !isCallable(args[0]) {
+				return newError("argument 1 to `apply` must be FUNCTION, got %s", args[0].Type())
+			}
+			arr, ok := args[1].(*object.Array)
+			if !ok {
+				return newError("argument 2 to `apply` must be ARRAY, got %s", args[1].Type())
+			}
+
+			// The callee may hold on to its arguments, so they get an array of their own
+			arguments := make([]object.Object, len(arr.Elements))
+			copy(arguments, arr.Elements)
+
+			return applyFunction(args[0], arguments)
+		},
+	}
+}
+
+// evalBuiltin is `eval`, which needs the environment of its caller, so
+// calls to it are intercepted by the evaluator and sent to evalSource
+var evalBuiltin = &object.Builtin{
+	Fn: func(args ...object.Object) object.Object {
+		return newError("`eval` must be called directly")
+	},
+}
+
+// evalSource evaluates the source given to `eval` in the caller's
+// environment, so it can read and create bindings there
+func evalSource(args []object.Object, env *object.Environment) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	source, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `eval` must be STRING, got %s", args[0].Type())
+	}
+
+	program, err := parseSource(source.Value)
+	if err != nil {
+		return err
+	}
+
+	// A return in the source ends the evaluated code, not the caller
+	result := unwrapReturnValue(Eval(program, env))
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// parseSource parses the source for `parse` and `eval`, returning an
+// error listing every parser error when it doesn't parse
+func parseSource(source string) (*ast.Program, *object.Error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+
+	if errs := p.ParseErrors(); len(errs) != 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return nil, newError("parse errors: %s", strings.Join(msgs, "; "))
+	}
+
+	return program, nil
+}
+
+// isCallable returns whether the object can be applied to arguments
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	default:
+		return false
+	}
+}
+
+// newHash builds a hash object keyed by strings
+func newHash(pairs map[string]object.Object) *object.Hash {
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+
+	for k, v := range pairs {
+		key := &object.String{Value: k}
+		hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: v}
+	}
+
+	return hash
+}
@@ -1,6 +1,8 @@
 package evaluator
 
 import (
+	"fmt"
+	"math"
 	"sugiru/ast"
 	"sugiru/object"
 )
@@ -9,6 +11,10 @@ var (
 	NULL  = &object.Null{}
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
+
+	// Loop control signals carry no state, so they can be shared
+	BREAK    = &object.BreakSignal{}
+	CONTINUE = &object.ContinueSignal{}
 )
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -18,33 +24,446 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func Eval(node ast.Node) object.Object {
+func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
+	case *ast.NullLiteral:
+		return NULL
+
 	case *ast.Program:
-		return evalStatements(node.Statements)
+		return evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression)
+		// Purely literal expressions skip the general dispatch
+		if obj, ok := evalLiteral(node.Expression); ok {
+			return obj
+		}
+		return Eval(node.Expression, env)
+
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+
+	case *ast.TernaryExpression:
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return Eval(node.Then, env)
+		}
+		return Eval(node.Else, env)
+
+	case *ast.LetStatement:
+		return evalLetStatement(node, env)
+
+	case *ast.MultiLetStatement:
+		// Bound one after the other, the first error stops the rest
+		for _, let := range node.Lets {
+			if result := evalLetStatement(let, env); result != nil {
+				return result
+			}
+		}
+		return nil
+
+	case *ast.DestructureStatement:
+		return evalDestructureStatement(node, env)
+
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.IndexAssignExpression:
+		return evalIndexAssignExpression(node, env)
+
+	case *ast.UpdateExpression:
+		return evalUpdateExpression(node, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
+
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+
+	case *ast.FunctionLiteral:
+		return &object.Function{
+			Name:       node.Name,
+			Parameters: node.Parameters,
+			Variadic:   node.Variadic,
+			Body:       node.Body,
+			Env:        env,
+		}
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right)
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
 		return evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left)
-		right := Eval(node.Right)
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node.Operator, left, node.Right, env)
+		}
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
 		return evalInfixExpression(node.Operator, left, right)
+
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+
+		args := evalArguments(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+
+		if function == evalBuiltin {
+			return evalSource(args, env)
+		}
+
+		return applyFunction(function, args)
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	}
+
+	return nil
+}
+
+// DISCARD is the throwaway name, values bound to it are dropped and reading it is an error.
+// Only the host can bind it, with Environment.Set, the REPL uses it for the last result.
+const DISCARD = "_"
+
+func evalLetStatement(node *ast.LetStatement, env *object.Environment) object.Object {
+	name := node.Name.Value
+
+	// The value is still evaluated for its side effects
+	if name == DISCARD {
+		if val := Eval(node.Value, env); isError(val) {
+			return val
+		}
+		return nil
+	}
+
+	// A constant can't be declared again in the same scope
+	if env.IsConst(name) {
+		return newError("cannot redeclare constant '%s'", name)
 	}
 
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Constant {
+		env.SetConst(name, val)
+	} else {
+		env.Set(name, val)
+	}
 	return nil
 }
 
+// evalDestructureStatement binds each element of the array to the name
+// at the same position, the array must have exactly as many elements
+func evalDestructureStatement(node *ast.DestructureStatement, env *object.Environment) object.Object {
+	for _, name := range node.Names {
+		if env.IsConst(name.Value) {
+			return newError("cannot redeclare constant '%s'", name.Value)
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	arr, ok := val.(*object.Array)
+	if !ok {
+		return newError("cannot destructure %s, want ARRAY", val.Type())
+	}
+	if len(arr.Elements) != len(node.Names) {
+		return newError("wrong number of values to destructure. got=%d, want=%d",
+			len(arr.Elements), len(node.Names))
+	}
+
+	for i, name := range node.Names {
+		switch {
+		case name.Value == DISCARD:
+			continue
+		case node.Constant:
+			env.SetConst(name.Value, arr.Elements[i])
+		default:
+			env.Set(name.Value, arr.Elements[i])
+		}
+	}
+	return nil
+}
+
+// evalAssignExpression rebinds an existing name in the scope it was
+// declared in, evaluating to the assigned value
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	name := node.Name.Value
+
+	if name == DISCARD {
+		return Eval(node.Value, env)
+	}
+
+	scope := env.Scope(name)
+	if scope == nil {
+		return newError("identifier not found: " + name)
+	}
+	if scope.IsConst(name) {
+		return newError("cannot assign to constant '%s'", name)
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	return scope.Set(name, val)
+}
+
+// evalUpdateExpression adds or subtracts one from an integer binding,
+// evaluating to the new value when prefix and the old value otherwise
+func evalUpdateExpression(node *ast.UpdateExpression, env *object.Environment) object.Object {
+	name := node.Name.Value
+
+	current := evalIdentifier(node.Name, env)
+	if isError(current) {
+		return current
+	}
+
+	// Builtins can be read but aren't bound in any scope
+	scope := env.Scope(name)
+	if scope == nil {
+		return newError("cannot assign to builtin '%s'", name)
+	}
+	if scope.IsConst(name) {
+		return newError("cannot assign to constant '%s'", name)
+	}
+
+	old, ok := current.(*object.Integer)
+	if !ok {
+		return newError("unknown operator: %s%s", node.Operator, current.Type())
+	}
+
+	updated := &object.Integer{Value: old.Value + 1}
+	if node.Operator == "--" {
+		updated.Value = old.Value - 1
+	}
+	scope.Set(name, updated)
+
+	if node.Prefix {
+		return updated
+	}
+	return old
+}
+
+// newError constructs an error object with a formatted message
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// isError returns whether the given object is an error object
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Value == DISCARD {
+		if val, ok := env.Get(DISCARD); ok {
+			return val
+		}
+		return newError("'%s' is not readable", DISCARD)
+	}
+
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+
+	if builtin, ok := builtins[node.Value]; ok && !(env.Sandboxed() && unsafeBuiltins[node.Value]) {
+		return builtin
+	}
+
+	return newError("identifier not found: " + node.Value)
+}
+
+// evalExpressions evaluates the expressions from left to right, if any
+// of them results in an error, a slice containing only that error is returned
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+// evalArguments evaluates the arguments of a call like evalExpressions,
+// the elements of a spread array each become an argument of their own
+func evalArguments(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		spread, ok := e.(*ast.SpreadExpression)
+		if !ok {
+			evaluated := Eval(e, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+			result = append(result, evaluated)
+			continue
+		}
+
+		evaluated := Eval(spread.Value, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		arr, ok := evaluated.(*object.Array)
+		if !ok {
+			return []object.Object{newError("cannot spread %s, want ARRAY", evaluated.Type())}
+		}
+		result = append(result, arr.Elements...)
+	}
+
+	return result
+}
+
+func applyFunction(fn object.Object, args []object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Variadic {
+			// The variadic parameter may be left with no arguments at all
+			if fixed := len(fn.Parameters) - 1; len(args) < fixed {
+				if fn.Name != "" {
+					return newError("wrong number of arguments to '%s': want at least %d, got=%d",
+						fn.Name, fixed, len(args))
+				}
+				return newError("wrong number of arguments: want at least %d, got=%d", fixed, len(args))
+			}
+		} else if len(args) != len(fn.Parameters) {
+			if fn.Name != "" {
+				return newError("wrong number of arguments to '%s': want=%d, got=%d",
+					fn.Name, len(fn.Parameters), len(args))
+			}
+			return newError("wrong number of arguments: want=%d, got=%d",
+				len(fn.Parameters), len(args))
+		}
+
+		extendedEnv := extendFunctionEnv(fn, args)
+		evaluated := evalBlockStatement(fn.Body, extendedEnv)
+
+		// An empty body, or one ending in a let, has no value of its own
+		if evaluated == nil {
+			return NULL
+		}
+		return loopSignalError(unwrapReturnValue(evaluated))
+	case *object.Builtin:
+		return fn.Fn(args...)
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+// extendFunctionEnv binds the arguments to the parameters of
+// the function in a scope enclosed by the function's environment
+func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+
+	params := fn.Parameters
+	if fn.Variadic {
+		// The last parameter gets an array of whatever is left
+		params = params[:len(params)-1]
+		rest := make([]object.Object, len(args)-len(params))
+		copy(rest, args[len(params):])
+
+		if last := fn.Parameters[len(fn.Parameters)-1]; last.Value != DISCARD {
+			env.Set(last.Value, &object.Array{Elements: rest})
+		}
+	}
+
+	for i, param := range params {
+		if param.Value != DISCARD {
+			env.Set(param.Value, args[i])
+		}
+	}
+
+	return env
+}
+
+// unwrapReturnValue stops a return value from propagating past the function it returns from
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	return obj
+}
+
 func evalInfixExpression(
 	operator string,
 	left object.Object,
@@ -52,11 +471,43 @@ func evalInfixExpression(
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumeric(left) && isNumeric(right):
+		// At least one side is a float, the other is promoted
+		return evalFloatInfixExpression(operator, floatValue(left), floatValue(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ && operator == "+":
+		return &object.String{Value: left.(*object.String).Value + right.(*object.String).Value}
+	case (operator == "<" || operator == ">") && left.Type() != right.Type():
+		// Mostly hit by chained comparisons such as `1 < 2 < 3`, which parse
+		// as `(1 < 2) < 3` and end up comparing a boolean with the integer
+		return newError("cannot compare %s with %s", left.Type(), right.Type())
+	case (left == NULL || right == NULL) && operator != "==" && operator != "!=":
+		// NULL doesn't propagate through arithmetic, `null + 1` is a mistake
+		// worth hearing about rather than a null turning up much later
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "==":
+		return nativeBoolToBooleanObject(left.Equal(right))
+	case operator == "!=":
+		return nativeBoolToBooleanObject(!left.Equal(right))
 	default:
 		return NULL
 	}
 }
 
+// evalLogicalExpression evaluates `&&` and `||`, which give back one of their
+// operands rather than a boolean. `a || b` is a when a is truthy and b
+// otherwise, `a && b` is b when a is truthy and a otherwise. The right side
+// is only evaluated when the left one doesn't already decide the result.
+func evalLogicalExpression(
+	operator string,
+	left object.Object,
+	right ast.Expression,
+	env *object.Environment) object.Object {
+	if isTruthy(left) == (operator == "||") {
+		return left
+	}
+	return Eval(right, env)
+}
+
 // evalIntegerInfixExpression takes an operator string and two objects of type Integer
 // and performs an arithmetic operation on the values of the object, depending on the
 // operator.
@@ -68,8 +519,9 @@ func evalInfixExpression(
 //
 // Returns:
 // - An object of type Integer, representing the result of the arithmetic operation.
+// - An object of type Boolean, for the comparison operators ('<', '>', '==', '!=').
 // - A NULL object, if the input objects are not of type Integer or if the operator is
-// not one of the supported arithmetic operators ('+', '-', '*', '/').
+// not one of the supported operators.
 func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
@@ -80,25 +532,116 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "-":
 		return &object.Integer{Value: leftVal - rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
+	case "%":
+		// The result takes the sign of the left operand, -7 % 3 is -1
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return newError("negative exponent: %d ** %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: intPow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
 		return NULL
 	}
 }
 
+// evalFloatInfixExpression follows IEEE 754, so dividing by zero doesn't
+// error but gives +Inf or -Inf ( NaN for 0.0 / 0 )
+func evalFloatInfixExpression(operator string, leftVal float64, rightVal float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return NULL
+	}
+}
+
+// intPow raises base to the non-negative exponent by repeated squaring,
+// overflowing the same way as the other integer operators
+func intPow(base int64, exponent int64) int64 {
+	result := int64(1)
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exponent >>= 1
+	}
+	return result
+}
+
+// isNumeric returns whether the object is an integer or a float
+func isNumeric(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// floatValue returns the value of a numeric object as a float
+func floatValue(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "+":
+		return evalPlusPrefixOperatorExpression(right)
 	default:
 		return NULL
 	}
 }
 
+// evalPlusPrefixOperatorExpression returns numeric operands unchanged,
+// unary plus on anything else is an error
+func evalPlusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right.Type() {
+	case object.INTEGER_OBJ, object.FLOAT_OBJ:
+		return right
+	default:
+		return newError("unknown operator: +%s", right.Type())
+	}
+}
+
 // evalMinusPrefixOperatorExpression takes an object of type Integer and
 // returns a new Integer object with its value negated.
 // If the input object is not an Integer, it returns a NULL object.
@@ -111,32 +654,297 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 // object of type Integer.
 // - A NULL object, otherwise.
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return NULL
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
+// evalBangOperatorExpression negates the truthiness of the operand,
+// so `!x` is true exactly when `if (x)` would take the else branch
 func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
+	return nativeBoolToBooleanObject(!isTruthy(right))
+}
+
+func evalIndexExpression(left object.Object, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// resolveIndex maps an index into [0, length), negative indices count
+// back from the end so -1 is the last element. The second result is
+// false if the index is out of range either way.
+func resolveIndex(index int64, length int) (int64, bool) {
+	if index < 0 {
+		index += int64(length)
+	}
+	if index < 0 || index >= int64(length) {
+		return 0, false
+	}
+	return index, true
+}
+
+func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx, ok := resolveIndex(index.(*object.Integer).Value, len(elements))
+	if !ok {
+		return NULL
+	}
+	return elements[idx]
+}
+
+// evalStringIndexExpression returns the character at the index as a new
+// string, strings are indexed by rune so multibyte characters stay whole
+func evalStringIndexExpression(str object.Object, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx, ok := resolveIndex(index.(*object.Integer).Value, len(runes))
+	if !ok {
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
+
+func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hash.(*object.Hash).Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+// evalIndexAssignExpression sets the element of the array or hash in place,
+// evaluating to the assigned value. Arrays can only set existing elements,
+// while hashes add the key when it's missing. A compound assignment reads
+// the element with the container and index it already evaluated.
+func evalIndexAssignExpression(node *ast.IndexAssignExpression, env *object.Environment) object.Object {
+	left := Eval(node.Target.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := Eval(node.Target.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	var current object.Object
+	if node.Operator != "" {
+		current = evalIndexExpression(left, index)
+		if isError(current) {
+			return current
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "" {
+		val = evalInfixExpression(node.Operator, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		integer, ok := index.(*object.Integer)
+		if !ok {
+			break
+		}
+		idx, ok := resolveIndex(integer.Value, len(left.Elements))
+		if !ok {
+			return newError("index out of range: %d, length %d", integer.Value, len(left.Elements))
+		}
+		left.Elements[idx] = val
+		return val
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+		return val
+	}
+
+	return newError("index assignment not supported: %s[%s]", left.Type(), index.Type())
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	var result object.Object
+	if isTruthy(condition) {
+		result = Eval(ie.Then, env)
+	} else if ie.Else != nil {
+		result = Eval(ie.Else, env)
+	}
+
+	// A missing else, an empty branch or one ending in a let is null
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// isTruthy returns whether the object counts as true in a condition or
+// under `!`. Everything except NULL and false is truthy, including 0, ""
+// and empty arrays or hashes.
+func isTruthy(obj object.Object) bool {
+	switch obj {
 	case NULL:
-		return FALSE
+		return false
+	case FALSE:
+		return false
 	default:
-		return FALSE
+		return true
 	}
 }
 
-func evalStatements(statements []ast.Statement) object.Object {
+// evalProgram evaluates the top level statements, a return stops the
+// program and is unwrapped into the value it returns
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		case *object.BreakSignal, *object.ContinueSignal:
+			return loopSignalError(result)
+		}
+	}
+
+	return result
+}
+
+// evalBlockStatement evaluates the statements of a block, a return or loop
+// control statement is kept wrapped so it propagates to the enclosing
+// function or loop, which is what stops it
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
-	for _, statement := range statements {
-		result = Eval(statement)
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+
+		// Stop at the first error, return or loop control statement
+		if result != nil {
+			switch result.Type() {
+			case object.ERROR_OBJ, object.RETURN_VALUE_OBJ,
+				object.BREAK_SIGNAL_OBJ, object.CONTINUE_SIGNAL_OBJ:
+				return result
+			}
+		}
 	}
 
 	return result
 }
+
+// loopSignalError turns a break or continue which escaped every loop,
+// reaching a function or program boundary, into an error
+func loopSignalError(obj object.Object) object.Object {
+	switch obj.(type) {
+	case *object.BreakSignal:
+		return newError("break outside of loop")
+	case *object.ContinueSignal:
+		return newError("continue outside of loop")
+	}
+	return obj
+}
+
+// evalForStatement runs the loop in its own scope, so the bindings made
+// by the init statement are gone once the loop finishes. Each iteration
+// of the body gets a fresh scope as well.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	loopEnv := object.NewEnclosedEnvironment(env)
+
+	if fs.Init != nil {
+		if init := Eval(fs.Init, loopEnv); isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if fs.Condition != nil {
+			condition := Eval(fs.Condition, loopEnv)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				break
+			}
+		}
+
+		// Stop the loop on errors and returns so they reach the caller
+		result := Eval(fs.Body, object.NewEnclosedEnvironment(loopEnv))
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+			if rt == object.BREAK_SIGNAL_OBJ {
+				break
+			}
+			// A continue simply falls through to the post statement
+		}
+
+		if fs.Post != nil {
+			if post := Eval(fs.Post, loopEnv); isError(post) {
+				return post
+			}
+		}
+	}
+
+	return NULL
+}
@@ -1,10 +1,18 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sugiru/ast"
 	"sugiru/lexer"
 	"sugiru/object"
 	"sugiru/parser"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -27,6 +35,14 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"10 - 2 - 3", 5},
+		{"20 / 4 / 2", 2},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"10 % 5", 0},
+		{"2 + 7 % 4 * 2", 8},
+		{"100 % 7 % 3", 2},
 	}
 
 	for _, tt := range tests {
@@ -39,8 +55,9 @@ func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
+	env := object.NewEnvironment()
 
-	return Eval(program)
+	return Eval(program, env)
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -64,6 +81,19 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}{
 		{"true", true},
 		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -95,6 +125,14 @@ func TestBangOperator(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!!5", true},
+		// Only NULL and false are falsy, the same as in an if
+		{"!0", false},
+		{"!!0", true},
+		{`!""`, false},
+		{"![]", false},
+		{"!{}", false},
+		{"!if (false) { 1 }", true},
+		{"!!if (false) { 1 }", false},
 	}
 
 	for _, tt := range tests {
@@ -102,3 +140,2242 @@ func TestBangOperator(t *testing.T) {
 		testBooleanObject(t, evaluated, tt.expected)
 	}
 }
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q",
+			expected, errObj.Message)
+		return false
+	}
+	return true
+}
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(`"Hello World!"`)
+	testStringObject(t, evaluated, "Hello World!")
+}
+
+func TestSubstrBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// Normal
+		{`substr("hello", 1, 3)`, "ell"},
+		{`substr("hello", 0, 5)`, "hello"},
+		{`substr("hello", 2, 0)`, ""},
+		{`substr("héllo", 1, 2)`, "él"},
+		// Clamped
+		{`substr("hello", 3, 10)`, "lo"},
+		{`substr("hello", 10, 2)`, ""},
+		{`substr("hello", -10, 2)`, "he"},
+		// Negative start
+		{`substr("hello", -3, 2)`, "ll"},
+		{`substr("hello", -1, 5)`, "o"},
+		// Misuse
+		{`substr("hello", 1)`, "wrong number of arguments. got=2, want=3"},
+		{`substr(1, 1, 1)`, "argument 1 to `substr` must be STRING, got INTEGER"},
+		{`substr("hello", true, 1)`, "argument 2 to `substr` must be INTEGER, got BOOLEAN"},
+		{`substr("hello", 1, "a")`, "argument 3 to `substr` must be INTEGER, got STRING"},
+		{`substr("hello", 1, -1)`, "length passed to `substr` must not be negative, got -1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			testErrorObject(t, errObj, tt.expected.(string))
+			continue
+		}
+		testStringObject(t, evaluated, tt.expected.(string))
+	}
+}
+
+func TestFloatLiteral(t *testing.T) {
+	evaluated := testEval("3.14")
+	result, ok := evaluated.(*object.Float)
+	if !ok {
+		t.Fatalf("object is not Float. got=%T (%+v)", evaluated, evaluated)
+	}
+	if result.Value != 3.14 {
+		t.Errorf("object has wrong value. got=%f, want=%f", result.Value, 3.14)
+	}
+}
+
+func TestNumberFormattingBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		isError  bool
+	}{
+		{`toString(255)`, "255", false},
+		{`toString(255, 16)`, "ff", false},
+		{`toString(5, 2)`, "101", false},
+		{`toString(-35, 36)`, "-z", false},
+		{`toFixed(3.14159, 2)`, "3.14", false},
+		{`toFixed(2.5, 0)`, "2", false},
+		{`toFixed(7, 3)`, "7.000", false},
+		{`toString(255, 1)`, "radix passed to `toString` must be between 2 and 36, got 1", true},
+		{`toString(255, 37)`, "radix passed to `toString` must be between 2 and 36, got 37", true},
+		{`toString("a")`, "argument 1 to `toString` must be INTEGER, got STRING", true},
+		{`toString()`, "wrong number of arguments. got=0, want=1 or 2", true},
+		{`toFixed(3.14, -1)`, "digits passed to `toFixed` must not be negative, got -1", true},
+		{`toFixed("3.14", 1)`, "argument 1 to `toFixed` must be FLOAT or INTEGER, got STRING", true},
+		{`toFixed(3.14)`, "wrong number of arguments. got=1, want=2", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if tt.isError {
+			testErrorObject(t, evaluated, tt.expected)
+		} else {
+			testStringObject(t, evaluated, tt.expected)
+		}
+	}
+}
+
+func TestFormatBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		isError  bool
+	}{
+		{`format("{} + {} = {}", 1, 2, 3)`, "1 + 2 = 3", false},
+		{`format("no placeholders")`, "no placeholders", false},
+		{`format("")`, "", false},
+		{`format("{}{}", "a", "b")`, "ab", false},
+		{`format("{} is {}", [1, 2], true)`, "[1, 2] is true", false},
+		{`format("{}", null)`, "null", false},
+		{`format("{{}} is {}", "empty")`, "{} is empty", false},
+		{`format("{{{}}}", 5)`, "{5}", false},
+		{`format("{ and }")`, "{ and }", false},
+		{`format("héllo {}", "wörld")`, "héllo wörld", false},
+		{`format("{}", ["a", 1])`, `["a", 1]`, false},
+		{`format("{} {}", 1)`, "format string has 2 placeholders, got 1 values", true},
+		{`format("{}", 1, 2)`, "format string has 1 placeholders, got 2 values", true},
+		{`format("{{}}", 1)`, "format string has 0 placeholders, got 1 values", true},
+		{`format()`, "wrong number of arguments. got=0, want at least 1", true},
+		{`format(1, 2)`, "argument 1 to `format` must be STRING, got INTEGER", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if tt.isError {
+			testErrorObject(t, evaluated, tt.expected)
+		} else {
+			testStringObject(t, evaluated, tt.expected)
+		}
+	}
+}
+
+func TestTokenizeBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`tokenize("1 + 2")`, `[{"literal": "1", "type": "INT"}, {"literal": "+", "type": "+"}, {"literal": "2", "type": "INT"}]`},
+		{`tokenize("let x = \"hi\"; // done")`, `[{"literal": "let", "type": "LET"}, {"literal": "x", "type": "IDENT"}, ` +
+			`{"literal": "=", "type": "="}, {"literal": "hi", "type": "STRING"}, {"literal": ";", "type": ";"}]`},
+		{`tokenize("a @")`, `[{"literal": "a", "type": "IDENT"}, {"literal": "@", "type": "ILLEGAL"}]`},
+		{`tokenize("")`, `[]`},
+		{`tokenize("  ")`, `[]`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if _, ok := evaluated.(*object.Array); !ok {
+			t.Errorf("%q: object is not Array. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong tokens.\nwant=%s\ngot=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// Each token is a hash, which can be indexed like any other
+	testStringObject(t, testEval(`tokenize("fn(x)")[0]["type"]`), "FUNCTION")
+
+	testErrorObject(t, testEval("tokenize(1)"), "argument to `tokenize` must be STRING, got INTEGER")
+	testErrorObject(t, testEval("tokenize()"), "wrong number of arguments. got=0, want=1")
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestUnknownIdentifier(t *testing.T) {
+	testErrorObject(t, testEval("foobar"), "identifier not found: foobar")
+}
+
+func TestPutsBuiltin(t *testing.T) {
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	evaluated := testEval(`puts("hello", 1, true)`)
+	if evaluated != NULL {
+		t.Errorf("puts should return NULL. got=%T (%+v)", evaluated, evaluated)
+	}
+	if out.String() != "hello\n1\ntrue\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+
+	// Only strings within other values are quoted
+	out.Reset()
+	testEval(`puts([1, "two", [3, "four"]], {"k": "v"})`)
+	if out.String() != "[1, \"two\", [3, \"four\"]]\n{\"k\": \"v\"}\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestCallArgumentErrors(t *testing.T) {
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	tests := []struct {
+		input    string
+		expected string
+		output   string
+	}{
+		// The error stops the remaining arguments and the call itself
+		{`let f = fn(a, b, c) { puts("called") }; f(puts("first"), -true, puts("third"))`,
+			"unknown operator: -BOOLEAN", "first\n"},
+		{`let f = fn(a, b, c) { puts("called") }; f(1, 10 / 0, puts("third"))`,
+			"division by zero", ""},
+		{`let f = fn(a, b) { puts("called") }; f(1, nope)`,
+			"identifier not found: nope", ""},
+		{`puts("a", -"x", "b")`,
+			"unknown operator: -STRING", ""},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		testErrorObject(t, testEval(tt.input), tt.expected)
+		if out.String() != tt.output {
+			t.Errorf("wrong output for %q. got=%q, want=%q", tt.input, out.String(), tt.output)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { return 10; } 1", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+		{"let f = fn(x) { return x; }; return f(10); 1", 10},
+		{"for (let i = 0; i < 5; i++) { if (i == 3) { return i; } } 1", 3},
+	}
+
+	for _, tt := range tests {
+		// The return value is unwrapped at the top level
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	testIntegerObject(t, testEval(`puts("before"); return 1; puts("after")`), 1)
+	if out.String() != "before\n" {
+		t.Errorf("the program went on after return. output=%q", out.String())
+	}
+
+	// A bare return leaves the function with null
+	bare := []string{
+		"let f = fn() { return; 1 }; f()",
+		"let f = fn() { return }; f()",
+		"let f = fn(x) { if (x > 0) { return; } x }; f(1)",
+		"let f = fn() { for (let i = 0; i < 5; i++) { return; } 1 }; f()",
+		"return;",
+	}
+	for _, input := range bare {
+		if evaluated := testEval(input); evaluated != NULL {
+			t.Errorf("%q: object is not NULL. got=%T (%+v)", input, evaluated, evaluated)
+		}
+	}
+	testIntegerObject(t, testEval("let f = fn(x) { if (x > 0) { return; } x }; f(-1)"), -1)
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
+	}
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+	if fn.Name != "" {
+		t.Errorf("anonymous function has a name. got=%q", fn.Name)
+	}
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fn add(x, y) { x + y }; add(2, 3)", 5},
+		{"fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } } fact(5)", 120},
+		{"fn fib(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(10)", 55},
+		{"fn outer() { fn inner() { 7 } inner() }; outer()", 7},
+		{"let x = 10; fn getX() { x }; x = 20; getX()", 20},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testErrorObject(t, testEval("const f = 1; fn f() { 2 }"), "cannot redeclare constant 'f'")
+	testErrorObject(t, testEval("fn add(x, y) { x + y }; add(1)"),
+		"wrong number of arguments to 'add': want=2, got=1")
+}
+
+func TestFunctionInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, y) { x + y }", "fn(x, y) { (x + y); }"},
+		{"fn() {}", "fn() { }"},
+		{"fn(n) { let m = n * 2; return m; }", "fn(n) { let m = (n * 2); return m; }"},
+		{"let double = fn(x) { x * 2 }; double", "fn double(x) { (x * 2); }"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		fn, ok := evaluated.(*object.Function)
+		if !ok {
+			t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+		}
+		if fn.Inspect() != tt.expected {
+			t.Errorf("fn.Inspect() wrong. want=%q, got=%q", tt.expected, fn.Inspect())
+		}
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let early = fn() { return 1; 2; }; early();", 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionsWithoutValue(t *testing.T) {
+	// An empty body, or one ending in a let, makes the call evaluate to null
+	bodies := []string{"fn() {}", "fn() { let y = 1 }"}
+
+	for _, body := range bodies {
+		tests := []struct {
+			input    string
+			expected interface{}
+		}{
+			{"let f = " + body + "; f()", nil},
+			{"let f = " + body + "; f() == 1", false},
+			{"let f = " + body + "; f() == null", true},
+			{"let f = " + body + "; let x = f(); x + 1", "unknown operator: NULL + INTEGER"},
+			{"let f = " + body + "; [f()]", "[null]"},
+			{"map([1], fn(x) " + strings.TrimPrefix(body, "fn() ") + ")", "[null]"},
+		}
+
+		for _, tt := range tests {
+			evaluated := testEval(tt.input)
+			switch expected := tt.expected.(type) {
+			case nil:
+				if evaluated != NULL {
+					t.Errorf("%q: object is not NULL. got=%T (%+v)", tt.input, evaluated, evaluated)
+				}
+			case bool:
+				testBooleanObject(t, evaluated, expected)
+			case string:
+				if _, ok := evaluated.(*object.Error); ok {
+					testErrorObject(t, evaluated, expected)
+				} else if evaluated == nil || evaluated.Inspect() != expected {
+					t.Errorf("%q: wrong result. want=%s, got=%+v", tt.input, expected, evaluated)
+				}
+			}
+		}
+
+		var out bytes.Buffer
+		Output = &out
+		testEval("let f = " + body + "; puts(f())")
+		Output = os.Stdout
+		if out.String() != "null\n" {
+			t.Errorf("%s: wrong output. got=%q", body, out.String())
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+  fn(y) { x + y };
+};
+let addTwo = newAdder(2);
+addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestNamedFunction(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(x, y) { x + y }; add(1);", "wrong number of arguments to 'add': want=2, got=1"},
+		{"fn(x, y) { x + y }(1, 2, 3);", "wrong number of arguments: want=2, got=3"},
+		{"let five = 5; five(1);", "not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+
+	// The name is kept even when the function is bound again
+	evaluated := testEval("let add = fn(x, y) { x + y }; let plus = add; plus;")
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+	if fn.Inspect() != "fn add(x, y) { (x + y); }" {
+		t.Errorf("fn.Inspect() wrong. got=%q", fn.Inspect())
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"let x = 5; if (x < 5) { 1 } else if (x == 5) { 2 } else { 3 }", 2},
+		{"let x = 1; if (x < 5) { 1 } else if (x == 5) { 2 } else { 3 }", 1},
+		{"let x = 9; if (x < 5) { 1 } else if (x == 5) { 2 } else { 3 }", 3},
+		{"let x = 9; if (x < 5) { 1 } else if (x == 5) { 2 }", nil},
+		// A branch without a value is null too
+		{"if (true) {}", nil},
+		{"if (false) { 1 } else {}", nil},
+		{"if (true) { let y = 1 }", nil},
+		{"if (false) { 1 } else { let y = 1 }", nil},
+		{"let x = if (true) {}; x", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != NULL {
+			t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+
+	// Such a branch can be used as a value
+	testBooleanObject(t, testEval("let x = if (true) {}; x == 1"), false)
+	testBooleanObject(t, testEval("let x = if (true) { let y = 1 }; x == null"), true)
+	testErrorObject(t, testEval("let x = if (false) { 1 } else {}; x + 1"), "unknown operator: NULL + INTEGER")
+	testStringObject(t, testEval(`str([if (true) { let y = 1 }])`), "[null]")
+}
+
+func TestSleepBuiltin(t *testing.T) {
+	var slept []time.Duration
+	Sleeper = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { Sleeper = time.Sleep }()
+
+	if evaluated := testEval("sleep(100)"); evaluated != NULL {
+		t.Errorf("sleep should return NULL. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(slept) != 1 || slept[0] != 100*time.Millisecond {
+		t.Errorf("sleeper called with wrong durations. got=%v", slept)
+	}
+
+	testErrorObject(t, testEval("sleep(-1)"), "duration passed to `sleep` must not be negative, got -1")
+	testErrorObject(t, testEval(`sleep("1")`), "argument to `sleep` must be INTEGER, got STRING")
+	testErrorObject(t, testEval("sleep()"), "wrong number of arguments. got=0, want=1")
+
+	if len(slept) != 1 {
+		t.Errorf("sleeper called on invalid arguments. got=%v", slept)
+	}
+}
+
+func TestNowBuiltin(t *testing.T) {
+	evaluated := testEval("now()")
+	now, ok := evaluated.(*object.Integer)
+	if !ok {
+		t.Fatalf("object is not Integer. got=%T (%+v)", evaluated, evaluated)
+	}
+	if now.Value <= 0 {
+		t.Errorf("now should be positive. got=%d", now.Value)
+	}
+
+	Clock = func() time.Time { return time.UnixMilli(1700000000123) }
+	defer func() { Clock = time.Now }()
+
+	testIntegerObject(t, testEval("now()"), 1700000000123)
+	testErrorObject(t, testEval("now(1)"), "wrong number of arguments. got=1, want=0")
+
+	// Sleeping for nothing returns straight away, with the real sleeper
+	if evaluated := testEval("sleep(0)"); evaluated != NULL {
+		t.Errorf("sleep should return NULL. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestRandomBuiltins(t *testing.T) {
+	// evalSequence seeds the generator and collects several draws
+	evalSequence := func() []int64 {
+		env := object.NewEnvironment()
+		Eval(parser.New(lexer.New("seed(42)")).ParseProgram(), env)
+
+		var values []int64
+		for i := 0; i < 5; i++ {
+			program := parser.New(lexer.New("randInt(-10, 10)")).ParseProgram()
+			integer, ok := Eval(program, env).(*object.Integer)
+			if !ok {
+				t.Fatalf("randInt did not return an Integer")
+			}
+			if integer.Value < -10 || integer.Value >= 10 {
+				t.Errorf("randInt out of range. got=%d", integer.Value)
+			}
+			values = append(values, integer.Value)
+		}
+		return values
+	}
+
+	first, second := evalSequence(), evalSequence()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("seeded sequences differ. first=%v, second=%v", first, second)
+		}
+	}
+
+	f, ok := testEval("rand()").(*object.Float)
+	if !ok {
+		t.Fatalf("rand did not return a Float")
+	}
+	if f.Value < 0 || f.Value >= 1 {
+		t.Errorf("rand out of range. got=%f", f.Value)
+	}
+
+	testIntegerObject(t, testEval("randInt(3, 4)"), 3)
+	testErrorObject(t, testEval("randInt(5, 5)"), "range passed to `randInt` is empty: [5, 5)")
+	testErrorObject(t, testEval("randInt(1)"), "wrong number of arguments. got=1, want=2")
+	testErrorObject(t, testEval("rand(1)"), "wrong number of arguments. got=1, want=0")
+	testErrorObject(t, testEval("seed(true)"), "argument to `seed` must be INTEGER, got BOOLEAN")
+
+	// Interpreters drawing and seeding at the same time share the generator safely
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			program := parser.New(lexer.New(fmt.Sprintf("seed(%d); for (let i = 0; i < 50; i++) { rand(); randInt(0, 5) }", n))).ParseProgram()
+			Eval(program, object.NewEnvironment())
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		// Negative indices count from the end
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-2]", 2},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][-4]", nil},
+		{"[][-1]", nil},
+		{`"abc"[0]`, "a"},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[-3]`, "a"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-4]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		default:
+			if evaluated != NULL {
+				t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+
+	testErrorObject(t, testEval(`[1][true]`), "index operator not supported: ARRAY[BOOLEAN]")
+	testErrorObject(t, testEval(`5[0]`), "index operator not supported: INTEGER[INTEGER]")
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = a * 2; a;", 10},
+		{"let a = 1; let f = fn() { a = 2; }; f(); a;", 2},
+		{"let a = 1; let f = fn() { let a = 5; a = 2; }; f(); a;", 1},
+		{"b = 1;", "identifier not found: b"},
+		// Assignment evaluates to the assigned value
+		{"let a = 1; a = 7", 7},
+		{"let a = 1; let b = 2; a = b = 5; a", 5},
+		{"let a = 1; let b = 2; a = b = 5; b", 5},
+		{"let a = 1; a = b = 5", "identifier not found: b"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const PI = 3; PI;", 3},
+		{"const PI = 3; PI = 4;", "cannot assign to constant 'PI'"},
+		{"const PI = 3; let f = fn() { PI = 4; }; f();", "cannot assign to constant 'PI'"},
+		{"const PI = 3; const PI = 4;", "cannot redeclare constant 'PI'"},
+		{"const PI = 3; let PI = 4;", "cannot redeclare constant 'PI'"},
+		// A new scope may shadow the constant
+		{"const PI = 3; let f = fn() { let PI = 4; PI = 5; PI }; f();", 5},
+		{"const PI = 3; let f = fn(PI) { PI = 5; PI }; f(1) + PI;", 8},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestLetShadowing(t *testing.T) {
+	testIntegerObject(t, testEval("let x = 1; let x = x + 1; x;"), 2)
+	testIntegerObject(t, testEval("let f = fn(x) { let x = x * 10; x }; f(2);"), 20)
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let double = fn(x) { x * 2 }; 5 |> double", 10},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3)", 7},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3, _)", -7},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(_, 3)", 7},
+		{"let double = fn(x) { x * 2 }; 1 + 2 |> double |> double", 12},
+		{`"hello" |> substr(1, 3)`, "ell"},
+		{`2 |> substr("hello", _, 3)`, "llo"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"hello"[1]`, "e"},
+		{`"hello"[4]`, "o"},
+		{`"hello"[5]`, nil},
+		// Strings are indexed by rune, not by byte
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[2]`, "l"},
+		{`"日本語"[2]`, "語"},
+		{`"日本語"[-3]`, "日"},
+		{`"日本語"[3]`, nil},
+		{`""[0]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(string); ok {
+			testStringObject(t, evaluated, expected)
+			continue
+		}
+		if evaluated != NULL {
+			t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestBenchmarkBuiltin(t *testing.T) {
+	// Every reading of the fake clock moves it forward by 5ms,
+	// so each timed call takes exactly 5ms
+	now := time.Unix(0, 0)
+	Clock = func() time.Time {
+		now = now.Add(5 * time.Millisecond)
+		return now
+	}
+	defer func() { Clock = time.Now }()
+
+	input := `let calls = 0;
+let f = fn() { calls = calls + 1 };
+let result = benchmark(f, 4);
+[result["total"], result["average"], calls]`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	testIntegerObject(t, result.Elements[0], int64(20*time.Millisecond))
+	testIntegerObject(t, result.Elements[1], int64(5*time.Millisecond))
+	testIntegerObject(t, result.Elements[2], 4)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"benchmark(fn() { 1 })", "wrong number of arguments. got=1, want=2"},
+		{"benchmark(1, 1)", "argument 1 to `benchmark` must be FUNCTION, got INTEGER"},
+		{"benchmark(fn(x) { x }, 1)", "function passed to `benchmark` must take no arguments, takes 1"},
+		{`benchmark(fn() { 1 }, "1")`, "argument 2 to `benchmark` must be INTEGER, got STRING"},
+		{"benchmark(fn() { 1 }, 0)", "iterations passed to `benchmark` must be positive, got 0"},
+		{"benchmark(fn() { foo }, 3)", "identifier not found: foo"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	4: 4,
+	true: 5,
+	false: 6
+}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey(): 1,
+		(&object.String{Value: "two"}).HashKey(): 2,
+		(&object.Integer{Value: 4}).HashKey():    4,
+		TRUE.HashKey():                           5,
+		FALSE.HashKey():                          6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != NULL {
+			t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+
+	testErrorObject(t, testEval(`{"name": "Monkey"}[fn(x) { x }];`), "unusable as hash key: FUNCTION")
+	testErrorObject(t, testEval(`{[1]: 2}`), "unusable as hash key: ARRAY")
+}
+
+func TestDeepEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" != "b"`, true},
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] != [1, 2]`, false},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[] == []`, true},
+		{`[1, [2, [3, "four"]]] == [1, [2, [3, "four"]]]`, true},
+		{`[1, [2, [3, "four"]]] == [1, [2, [3, "five"]]]`, false},
+		{`[1, true] == [1, 1]`, false},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{1: "x"} == {true: "x"}`, false},
+		{`{"a": {"b": [1]}} == {"a": {"b": [1]}}`, true},
+		{`[{"a": 1}] != [{"a": 1}]`, false},
+		{`let f = fn() { 1 }; f == f`, true},
+		{`fn() { 1 } == fn() { 1 }`, false},
+		{`1 == "1"`, false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestUnaryPlus(t *testing.T) {
+	testIntegerObject(t, testEval("+5"), 5)
+	testIntegerObject(t, testEval("let x = -3; +x"), -3)
+	testIntegerObject(t, testEval("2 + +3"), 5)
+
+	f, ok := testEval("+1.5").(*object.Float)
+	if !ok || f.Value != 1.5 {
+		t.Errorf("+1.5 did not evaluate to 1.5. got=%+v", f)
+	}
+
+	testErrorObject(t, testEval("+true"), "unknown operator: +BOOLEAN")
+	testErrorObject(t, testEval(`+"a"`), "unknown operator: +STRING")
+}
+
+func TestTypePredicateBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"isInt(1)", true},
+		{"isInt(1.5)", false},
+		{`isInt("1")`, false},
+		{"isFloat(1.5)", true},
+		{`isString("a")`, true},
+		{"isString(1)", false},
+		{"isArray([])", true},
+		{"isArray({})", false},
+		{"isHash({})", true},
+		{"isHash([])", false},
+		{"isFunction(fn() {})", true},
+		{"isFunction(puts)", true},
+		{"isFunction(1)", false},
+		{"isNull(if (false) { 1 })", true},
+		{"isNull(0)", false},
+		{"isBool(false)", true},
+		{"isBool(0)", false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testErrorObject(t, testEval("isInt()"), "wrong number of arguments to `isInt`. got=0, want=1")
+	testErrorObject(t, testEval("isNull(1, 2)"), "wrong number of arguments to `isNull`. got=2, want=1")
+}
+
+func TestNullValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"is_null(null)", true},
+		{"is_null(0)", false},
+		{"is_null(false)", false},
+		{`is_null("")`, false},
+		{"is_null(if (false) { 1 })", true},
+		// NULL is falsy wherever a condition is tested
+		{"if (null) { 1 } else { 2 }", 2},
+		{"if (!null) { 1 } else { 2 }", 1},
+		{"!null", true},
+		{"!!null", false},
+		{"null ? 1 : 2", 2},
+		{"null == null", true},
+		{"null != 0", true},
+		// Arithmetic doesn't propagate NULL, it errors
+		{"null + 1", "unknown operator: NULL + INTEGER"},
+		{"2 * null", "unknown operator: INTEGER * NULL"},
+		{`"a" + null`, "unknown operator: STRING + NULL"},
+		{"let x = if (false) { 1 }; x - 1", "unknown operator: NULL - INTEGER"},
+		{"is_null == isNull", true},
+		{"is_null()", "wrong number of arguments to `isNull`. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// Only NULL and false are falsy, so 0 is kept by ||
+		{"0 || 5", 0},
+		{"3 && 4", 4},
+		{"null || 5", 5},
+		{"false || 5", 5},
+		{"false && 5", false},
+		{"null && 5", nil},
+		{"true && false", false},
+		{"false || false", false},
+		{"true || false", true},
+		{`let maybe = if (false) { 1 }; maybe || "default"`, "default"},
+		{`let maybe = "set"; maybe || "default"`, "set"},
+		{"1 < 2 && 2 < 3", true},
+		{"1 && 2 || 3", 2},
+		{"false && 2 || 3", 3},
+		// The right side isn't evaluated when the left decides the result
+		{"true || missing", true},
+		{"false && missing", false},
+		{"null && 1 / 0", nil},
+		{"false || missing", "identifier not found: missing"},
+		{"-true || 1", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			if evaluated != NULL {
+				t.Errorf("%q: object is not NULL. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				testStringObject(t, str, expected)
+			} else {
+				testErrorObject(t, evaluated, expected)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	testEval(`true || puts("skipped"); false && puts("skipped"); false || puts("ran")`)
+	if out.String() != "ran\n" {
+		t.Errorf("wrong side effects. got=%q", out.String())
+	}
+}
+
+func TestTernaryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"true ? 1 : 2", 1},
+		{"false ? 1 : 2", 2},
+		{"let a = 3; let b = 7; a > b ? a : b", 7},
+		{"let a = 9; let b = 7; a > b ? a : b", 9},
+		{"1 ? 10 : 20", 10},
+		{"let x = 0; x < 0 ? 1 : x == 0 ? 2 : 3", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	// Only the chosen branch is evaluated
+	testIntegerObject(t, testEval("true ? 1 : missing"), 1)
+	testErrorObject(t, testEval("false ? 1 : missing"), "identifier not found: missing")
+}
+
+func testIntegerArray(t *testing.T, obj object.Object, expected []int64) bool {
+	array, ok := obj.(*object.Array)
+	if !ok {
+		t.Errorf("object is not Array. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if len(array.Elements) != len(expected) {
+		t.Errorf("wrong num of elements. want=%d, got=%d (%s)",
+			len(expected), len(array.Elements), array.Inspect())
+		return false
+	}
+	for i, v := range expected {
+		if !testIntegerObject(t, array.Elements[i], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestScanBuiltin(t *testing.T) {
+	testIntegerArray(t, testEval("let add = fn(a, b) { a + b }; scan([1, 2, 3], 0, add)"), []int64{1, 3, 6})
+	testIntegerArray(t, testEval("scan([1, 2, 3], 10, fn(acc, x) { acc * x })"), []int64{10, 20, 60})
+	testIntegerArray(t, testEval("scan([], 0, fn(acc, x) { acc + x })"), []int64{})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"scan([1], 0)", "wrong number of arguments. got=2, want=3"},
+		{"scan(1, 0, fn(a, b) { a })", "argument 1 to `scan` must be ARRAY, got INTEGER"},
+		{"scan([1], 0, 1)", "argument 3 to `scan` must be FUNCTION, got INTEGER"},
+		{"scan([1, 2], 0, fn(a, b) { a + c })", "identifier not found: c"},
+		{"scan([1], 0, fn(a) { a })", "wrong number of arguments: want=1, got=2"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestPartitionBuiltin(t *testing.T) {
+	testPartition := func(input string, matching, nonMatching []int64) {
+		t.Helper()
+		result, ok := testEval(input).(*object.Array)
+		if !ok || len(result.Elements) != 2 {
+			t.Fatalf("partition did not return a pair. input=%q got=%v", input, result)
+		}
+		testIntegerArray(t, result.Elements[0], matching)
+		testIntegerArray(t, result.Elements[1], nonMatching)
+	}
+
+	testPartition("partition([1, 2, 3, 4, 5], fn(x) { x / 2 * 2 == x })", []int64{2, 4}, []int64{1, 3, 5})
+	testPartition("partition([], fn(x) { true })", []int64{}, []int64{})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"partition([1])", "wrong number of arguments. got=1, want=2"},
+		{"partition(1, fn(x) { x })", "argument 1 to `partition` must be ARRAY, got INTEGER"},
+		{"partition([1], 1)", "argument 2 to `partition` must be FUNCTION, got INTEGER"},
+		{"partition([1], fn(x) { y })", "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestApplyBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let add = fn(a, b) { a + b }; apply(add, [1, 2])", 3},
+		{"apply(fn() { 7 }, [])", 7},
+		{"apply(fn(a, b, c) { a * b - c }, [2, 3, 1])", 5},
+		{"apply(max, [3, 9, 4])", 9},
+		{"let args = [2, 8]; apply(fn(a, b) { b / a }, args)", 4},
+		{"apply(apply, [fn(x) { x + 1 }, [1]])", 2},
+		{"let add = fn(a, b) { a + b }; apply(add, [1])", "wrong number of arguments to 'add': want=2, got=1"},
+		{"let add = fn(a, b) { a + b }; apply(add, [1, 2, 3])", "wrong number of arguments to 'add': want=2, got=3"},
+		{"apply(fn(x) { x }, [])", "wrong number of arguments: want=1, got=0"},
+		{"apply(1, [1])", "argument 1 to `apply` must be FUNCTION, got INTEGER"},
+		{"apply(fn(x) { x }, 1)", "argument 2 to `apply` must be ARRAY, got INTEGER"},
+		{"apply(fn(x) { x })", "wrong number of arguments. got=1, want=2"},
+		{"apply(fn(x) { -x }, [true])", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestVariadicFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn sum(...xs) { reduce(xs, 0, fn(acc, x) { acc + x }) }; sum(1, 2, 3)", 6},
+		{"fn sum(...xs) { reduce(xs, 0, fn(acc, x) { acc + x }) }; sum()", 0},
+		{"let count = fn(...xs) { reduce(xs, 0, fn(acc, x) { acc + 1 }) }; count(1, 2, 3, 4)", 4},
+		{"let f = fn(a, b, ...rest) { a * b + reduce(rest, 0, fn(acc, x) { acc + x }) }; f(2, 3)", 6},
+		{"let f = fn(a, b, ...rest) { a * b + reduce(rest, 0, fn(acc, x) { acc + x }) }; f(2, 3, 4, 5)", 15},
+		{"let first = fn(...xs) { xs[0] }; first(...[7, 8])", 7},
+		{"let f = fn(a, ...rest) { a }; apply(f, [1, 2, 3])", 1},
+		{"fn f(a, b, ...rest) { a }; f(1)", "wrong number of arguments to 'f': want at least 2, got=1"},
+		{"let f = fn(a, ...rest) { a }; f()", "wrong number of arguments to 'f': want at least 1, got=0"},
+		{"(fn(a, ...rest) { a })()", "wrong number of arguments: want at least 1, got=0"},
+		{"let f = fn(..._) { 1 }; f(1, 2)", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+
+	// The rest is always an array, empty when nothing is left over
+	rest := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, ...rest) { rest }; f(1)", "[]"},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{`let f = fn(...rest) { rest }; f("a", [1])`, `["a", [1]]`},
+	}
+	for _, tt := range rest {
+		evaluated := testEval(tt.input)
+		if _, ok := evaluated.(*object.Array); !ok || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong rest. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	if inspect := testEval("fn f(a, ...rest) { a }; f").Inspect(); inspect != "fn f(a, ...rest) { a; }" {
+		t.Errorf("wrong Inspect. got=%q", inspect)
+	}
+}
+
+func TestSpreadArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let nums = [3, 9, 4]; max(...nums)", 9},
+		{"min(...[5, 2], 7, ...[3])", 2},
+		{"let add = fn(a, b, c) { a + b * c }; add(...[1, 2, 3])", 7},
+		{"let add = fn(a, b, c) { a + b * c }; add(1, ...[2], 3)", 7},
+		{"let f = fn() { 1 }; f(...[])", 1},
+		{"let f = fn(a, b) { a - b }; 1 |> f(...[5])", -4},
+		{"let add = fn(a, b) { a + b }; add(...[1, 2, 3])", "wrong number of arguments to 'add': want=2, got=3"},
+		{"max(...5)", "cannot spread INTEGER, want ARRAY"},
+		{`max(1, ..."ab")`, "cannot spread STRING, want ARRAY"},
+		{"max(...missing)", "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestFlatMapBuiltin(t *testing.T) {
+	testIntegerArray(t, testEval("flatMap([1, 2, 3], fn(x) { [x, x * 10] })"), []int64{1, 10, 2, 20, 3, 30})
+	testIntegerArray(t, testEval("flatMap([1, 2], fn(x) { [] })"), []int64{})
+	testIntegerArray(t, testEval("flatMap([], fn(x) { [x] })"), []int64{})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"flatMap([1])", "wrong number of arguments. got=1, want=2"},
+		{"flatMap(1, fn(x) { [x] })", "argument 1 to `flatMap` must be ARRAY, got INTEGER"},
+		{"flatMap([1], 1)", "argument 2 to `flatMap` must be FUNCTION, got INTEGER"},
+		{"flatMap([1, 2], fn(x) { x })", "function passed to `flatMap` must return ARRAY, got INTEGER"},
+		{"flatMap([1], fn(x) { y })", "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAllAndAnyBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"all([2, 4, 6], fn(x) { x / 2 * 2 == x })", true},
+		{"all([2, 3, 6], fn(x) { x / 2 * 2 == x })", false},
+		{"all([], fn(x) { false })", true},
+		{"any([1, 3, 4], fn(x) { x / 2 * 2 == x })", true},
+		{"any([1, 3, 5], fn(x) { x / 2 * 2 == x })", false},
+		{"any([], fn(x) { true })", false},
+		// The second element would error if it were ever evaluated
+		{"all([1, 2], fn(x) { if (x == 1) { false } else { y } })", false},
+		{"any([1, 2], fn(x) { if (x == 1) { true } else { y } })", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"all([1])", "wrong number of arguments to `all`. got=1, want=2"},
+		{"any(1, fn(x) { x })", "argument 1 to `any` must be ARRAY, got INTEGER"},
+		{"all([1], 1)", "argument 2 to `all` must be FUNCTION, got INTEGER"},
+		{"any([1], fn(x) { y })", "identifier not found: y"},
+	}
+
+	for _, tt := range errors {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let sum = 0; for (let i = 1; i < 5; i = i + 1) { sum = sum + i }; sum", 10},
+		{"let n = 0; for (; n < 3;) { n = n + 1 }; n", 3},
+		{"let sum = 0; for (let i = 0; i < 0; i = i + 1) { sum = 99 }; sum", 0},
+		// Returning leaves the loop and the enclosing function
+		{"let f = fn() { for (let i = 0; true; i = i + 1) { if (i == 7) { return i } } }; f()", 7},
+		// The body gets a fresh scope on every iteration
+		{"let total = 0; for (let i = 0; i < 3; i = i + 1) { const twice = i * 2; total = total + twice }; total", 6},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < 3; i = i + 1) { }; i", "identifier not found: i"},
+		{"for (let i = 0; i < y; i = i + 1) { }", "identifier not found: y"},
+		{"for (let i = 0; i < 3; i = i + 1) { z }", "identifier not found: z"},
+		{"for (let i = 0; i < 3; j = 1) { }", "identifier not found: j"},
+	}
+
+	for _, tt := range errors {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let n = 0; for (let i = 0; i < 10; i = i + 1) { if (i == 4) { break }; n = n + 1 }; n", 4},
+		{"let n = 0; for (;;) { n = n + 1; if (n == 3) { break } }; n", 3},
+		// Only odd numbers are summed: 1 + 3 + 5
+		{"let sum = 0; for (let i = 0; i < 6; i = i + 1) { if (i / 2 * 2 == i) { continue }; sum = sum + i }; sum", 9},
+		// A break only leaves the innermost loop
+		{"let n = 0; for (let i = 0; i < 3; i = i + 1) { for (let j = 0; j < 3; j = j + 1) { if (j == 1) { break }; n = n + 1 } }; n", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"break", "break outside of loop"},
+		{"if (true) { continue }", "continue outside of loop"},
+		// Loop control doesn't cross function boundaries
+		{"for (;;) { fn() { break }() }", "break outside of loop"},
+	}
+
+	for _, tt := range errors {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// BenchmarkRecursiveCalls exercises the environment created for every call
+func BenchmarkRecursiveCalls(b *testing.B) {
+	program := parser.New(lexer.New(`
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let leaf = fn() { fib };
+let count = fn(n) { if (n == 0) { leaf() } else { count(n - 1) } };
+fib(15);
+count(200);
+`)).ParseProgram()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5 + 2", 3.5},
+		{"2 + 1.5", 3.5},
+		{"3.0 / 2", 1.5},
+		{"0.5 * 4", 2.0},
+		{"1 - 0.25", 0.75},
+		{"1.5 + 1.5 * 2", 4.5},
+		{"1.0 / 0", math.Inf(1)},
+		{"-1 / 0.0", math.Inf(-1)},
+		{"7.5 % 2", 1.5},
+		{"-7.5 % 2", -1.5},
+		{"7 % 2.5", 2},
+	}
+
+	for _, tt := range tests {
+		testFloatObject(t, testEval(tt.input), tt.expected)
+	}
+
+	comparisons := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5 < 2", true},
+		{"2.5 > 3", false},
+		{"0.1 + 0.2 > 0.3", true},
+		{"2.0 == 2", true},
+		{"1 != 1.5", true},
+		{"1.25 == 1.25", true},
+	}
+
+	for _, tt := range comparisons {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	t.Helper()
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestMapBuiltin(t *testing.T) {
+	testIntegerArray(t, testEval("map([1, 2, 3], fn(x) { x * 2 })"), []int64{2, 4, 6})
+	testIntegerArray(t, testEval("let double = fn(x) { x * 2 }; map(map([1, 2], double), double)"), []int64{4, 8})
+	testIntegerArray(t, testEval("map([], fn(x) { x })"), []int64{})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map([1])", "wrong number of arguments. got=1, want=2"},
+		{"map(1, fn(x) { x })", "argument 1 to `map` must be ARRAY, got INTEGER"},
+		{"map([1], 1)", "argument 2 to `map` must be FUNCTION, got INTEGER"},
+		{"map([1], fn(x) { y })", "identifier not found: y"},
+		{"map([1], fn(x, y) { x })", "wrong number of arguments: want=2, got=1"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestParseBuiltin(t *testing.T) {
+	testStringObject(t, testEval(`parse("let x = 1 + 2 * 3; x")`), "let x = (1 + (2 * 3));x")
+	testStringObject(t, testEval(`parse("")`), "")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`parse("(1")`, "parse errors: 1:3: expected next token to be ), got EOF instead"},
+		{`parse("let x 1; let y 2")`, "parse errors: 1:7: expected next token to be =, got INT instead; 1:16: expected next token to be =, got INT instead"},
+		{`parse(1)`, "argument to `parse` must be STRING, got INTEGER"},
+		{`parse()`, "wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssertBuiltin(t *testing.T) {
+	passing := []string{
+		"assert(1 + 1 == 2)",
+		"assert(true, \"never shown\")",
+		"assert(0)",
+		"assert([])",
+	}
+
+	for _, input := range passing {
+		if evaluated := testEval(input); evaluated != NULL {
+			t.Errorf("%q should return NULL. got=%T (%+v)", input, evaluated, evaluated)
+		}
+	}
+
+	failing := []struct {
+		input    string
+		expected string
+	}{
+		{"assert(false)", "assertion failed"},
+		{"assert(null)", "assertion failed"},
+		{`assert(1 + 1 == 3, "math is broken")`, "assertion failed: math is broken"},
+		// The failure stops the rest of the program
+		{"let f = fn() { assert(false); 1 }; f() + 1", "assertion failed"},
+	}
+
+	for _, tt := range failing {
+		evaluated := testEval(tt.input)
+		if !testErrorObject(t, evaluated, tt.expected) {
+			continue
+		}
+		if !evaluated.(*object.Error).Assertion {
+			t.Errorf("%q is not an assertion error", tt.input)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"assert()", "wrong number of arguments. got=0, want=1 or 2"},
+		{"assert(true, 1)", "argument 2 to `assert` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		if testErrorObject(t, evaluated, tt.expected) && evaluated.(*object.Error).Assertion {
+			t.Errorf("%q is an assertion error", tt.input)
+		}
+	}
+}
+
+func TestCloneBuiltin(t *testing.T) {
+	evaluated := testEval("let a = [1, [2, 3]]; let b = clone(a); [a, b]")
+	pair, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	original, clone := pair.Elements[0].(*object.Array), pair.Elements[1].(*object.Array)
+	if original == clone {
+		t.Fatalf("clone returned the same array")
+	}
+	if clone.Inspect() != "[1, [2, 3]]" {
+		t.Errorf("clone has wrong elements. got=%s", clone.Inspect())
+	}
+
+	// The copy is shallow, the elements themselves are shared
+	if original.Elements[1] != clone.Elements[1] {
+		t.Errorf("nested array was copied")
+	}
+	clone.Elements[0] = &object.Integer{Value: 9}
+	if original.Inspect() != "[1, [2, 3]]" {
+		t.Errorf("changing the clone changed the original. got=%s", original.Inspect())
+	}
+
+	evaluated = testEval(`let h = {"a": 1, "b": [2]}; let c = clone(h); [h, c]`)
+	pair = evaluated.(*object.Array)
+	hash, hashClone := pair.Elements[0].(*object.Hash), pair.Elements[1].(*object.Hash)
+	if hashClone.Inspect() != `{"a": 1, "b": [2]}` {
+		t.Errorf("clone has wrong pairs. got=%s", hashClone.Inspect())
+	}
+
+	key := (&object.String{Value: "c"}).HashKey()
+	hashClone.Pairs[key] = object.HashPair{Key: &object.String{Value: "c"}, Value: TRUE}
+	if len(hash.Pairs) != 2 {
+		t.Errorf("changing the clone changed the original. got=%s", hash.Inspect())
+	}
+
+	testIntegerArray(t, testEval("clone([])"), []int64{})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"clone()", "wrong number of arguments. got=0, want=1"},
+		{"clone(1)", "argument to `clone` must be ARRAY or HASH, got INTEGER"},
+		{`clone("ab")`, "argument to `clone` must be ARRAY or HASH, got STRING"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestReduceBuiltin(t *testing.T) {
+	testIntegerObject(t, testEval("reduce([1, 2, 3, 4], 0, fn(acc, x) { acc + x })"), 10)
+	testIntegerObject(t, testEval("reduce([], 42, fn(acc, x) { acc + x })"), 42)
+	testStringObject(t, testEval(`reduce(["a", "b", "c"], "", fn(acc, s) { acc + s })`), "abc")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"reduce([1], 0)", "wrong number of arguments. got=2, want=3"},
+		{"reduce(1, 0, fn(a, b) { a })", "argument 1 to `reduce` must be ARRAY, got INTEGER"},
+		{"reduce([1], 0, 1)", "argument 3 to `reduce` must be FUNCTION, got INTEGER"},
+		{"reduce([1], 0, fn(a) { a })", "wrong number of arguments: want=1, got=2"},
+		{"reduce([1, 2], 0, fn(a, b) { c })", "identifier not found: c"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	testStringObject(t, testEval(`"Hello" + " " + "World!"`), "Hello World!")
+	testStringObject(t, testEval(`let s = "ab"; s + s`), "abab")
+}
+
+func TestEvalBuiltin(t *testing.T) {
+	testIntegerObject(t, testEval(`eval("1 + 2")`), 3)
+	testIntegerObject(t, testEval(`eval("let x = 5;"); x * 2`), 10)
+	testIntegerObject(t, testEval(`let y = 4; eval("y + 1")`), 5)
+	testIntegerObject(t, testEval(`let f = fn() { let a = 1; eval("a + 1") }; f()`), 2)
+	testIntegerObject(t, testEval(`eval("return 7; 8")`), 7)
+
+	if result := testEval(`eval("")`); result != NULL {
+		t.Errorf("eval of empty source is not NULL. got=%T (%+v)", result, result)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`eval("(1")`, "parse errors: 1:3: expected next token to be ), got EOF instead"},
+		{`eval("z")`, "identifier not found: z"},
+		{`eval(1)`, "argument to `eval` must be STRING, got INTEGER"},
+		{`eval("1", "2")`, "wrong number of arguments. got=2, want=1"},
+		{`map(["1"], eval)`, "`eval` must be called directly"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEvalBuiltinSandboxed(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`eval("1 + 2")`, "identifier not found: eval"},
+		{`let f = fn() { eval("1") }; f()`, "identifier not found: eval"},
+		{`let eval = fn(s) { 1 }; eval("2")`, 1},
+		{"abs(-3)", 3},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := Eval(program, object.NewSandboxedEnvironment())
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+
+	// Only the environments asking for it are sandboxed
+	testIntegerObject(t, testEval(`eval("1 + 2")`), 3)
+}
+
+func TestFilterBuiltin(t *testing.T) {
+	testIntegerArray(t, testEval("filter([1, 2, 3, 4], fn(x) { x > 2 })"), []int64{3, 4})
+	testIntegerArray(t, testEval("filter([1, 2, 3], fn(x) { false })"), []int64{})
+	testIntegerArray(t, testEval("filter([1, 2, 3], fn(x) { true })"), []int64{1, 2, 3})
+	// Only NULL and false are falsy, like in an if
+	testIntegerArray(t, testEval("filter([0, 1, 2], fn(x) { if (x > 0) { x } })"), []int64{1, 2})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"filter([1])", "wrong number of arguments. got=1, want=2"},
+		{"filter(1, fn(x) { x })", "argument 1 to `filter` must be ARRAY, got INTEGER"},
+		{"filter([1], 1)", "argument 2 to `filter` must be FUNCTION, got INTEGER"},
+		{"filter([1], fn(x) { y })", "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestLiteralFastPath(t *testing.T) {
+	tests := []struct {
+		input  string
+		folded bool // Whether the fast path handles it
+	}{
+		{"1 + 2 * 3", true},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", true},
+		{"-(+7) / 2", true},
+		{"1 < 2 == true", true},
+		{"!(1 > 2) != false", true},
+		{"9223372036854775807 + 1", true},
+		{"1 / 0 + 1", false},
+		{"-17 % 5 * 2", true},
+		{"1 % 0", false},
+		{"!5", true},
+		{"!!0 == true", true},
+		{"true + 1", false},
+		{"1.5 + 2", false},
+		{"x + 1", false},
+		{`"a" == "a"`, false},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		exp := program.Statements[0].(*ast.ExpressionStatement).Expression
+
+		folded, ok := evalLiteral(exp)
+		if ok != tt.folded {
+			t.Errorf("%q folded=%t, want=%t", tt.input, ok, tt.folded)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		// Evaluating the expression node directly bypasses the fast path
+		expected := Eval(exp, object.NewEnvironment())
+		if folded.Type() != expected.Type() || folded.Inspect() != expected.Inspect() {
+			t.Errorf("%q fast path gave %s, normal evaluation %s",
+				tt.input, folded.Inspect(), expected.Inspect())
+		}
+	}
+}
+
+func BenchmarkLiteralFastPath(b *testing.B) {
+	program := parser.New(lexer.New("(5 + 10 * 2 + 15 / 3) * 2 + -10 == 50")).ParseProgram()
+	env := object.NewEnvironment()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Eval(program, env)
+	}
+}
+
+func BenchmarkLiteralGeneralPath(b *testing.B) {
+	program := parser.New(lexer.New("(5 + 10 * 2 + 15 / 3) * 2 + -10 == 50")).ParseProgram()
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression
+	env := object.NewEnvironment()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Eval(exp, env)
+	}
+}
+
+func TestSplitAndJoinBuiltins(t *testing.T) {
+	testStrings := func(obj object.Object, expected []string) {
+		t.Helper()
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			t.Fatalf("object is not Array. got=%T (%+v)", obj, obj)
+		}
+		if len(arr.Elements) != len(expected) {
+			t.Fatalf("wrong number of elements. want=%d, got=%d", len(expected), len(arr.Elements))
+		}
+		for i, el := range expected {
+			testStringObject(t, arr.Elements[i], el)
+		}
+	}
+
+	testStrings(testEval(`split("a,b,c", ",")`), []string{"a", "b", "c"})
+	testStrings(testEval(`split("a, b", ", ")`), []string{"a", "b"})
+	testStrings(testEval(`split("a,,b,", ",")`), []string{"a", "", "b", ""})
+	testStrings(testEval(`split("abc", "-")`), []string{"abc"})
+	testStrings(testEval(`split("héy", "")`), []string{"h", "é", "y"})
+	testStrings(testEval(`split("", ",")`), []string{})
+
+	testStringObject(t, testEval(`join(["a", "b"], "-")`), "a-b")
+	testStringObject(t, testEval(`join(["a", "b", "c"], "")`), "abc")
+	testStringObject(t, testEval(`join([], ",")`), "")
+	testStringObject(t, testEval(`join(split("a,b,c", ","), ";")`), "a;b;c")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a")`, "wrong number of arguments. got=1, want=2"},
+		{`split(1, ",")`, "argument 1 to `split` must be STRING, got INTEGER"},
+		{`split("a", 1)`, "argument 2 to `split` must be STRING, got INTEGER"},
+		{`join(["a"])`, "wrong number of arguments. got=1, want=2"},
+		{`join("a", ",")`, "argument 1 to `join` must be ARRAY, got STRING"},
+		{`join(["a"], 1)`, "argument 2 to `join` must be STRING, got INTEGER"},
+		{`join(["a", 1], ",")`, "element 1 passed to `join` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAbsMinMaxBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"abs(-5)", 5},
+		{"abs(5)", 5},
+		{"abs(0)", 0},
+		{"abs(-2.5)", 2.5},
+		{"min(3, 7)", 3},
+		{"max(3, 7)", 7},
+		{"min(4, -1, 9, 2)", -1},
+		{"max(4, -1, 9, 2)", 9},
+		{"min(1.5, 0.5)", 0.5},
+		// Mixed arguments compare by value and keep the winner's type
+		{"min(1, 2.5)", 1},
+		{"max(1, 2.5)", 2.5},
+		{"max(-1.5, -2)", -1.5},
+		{"abs()", "wrong number of arguments. got=0, want=1"},
+		{"abs(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{`abs("a")`, "argument to `abs` must be FLOAT or INTEGER, got STRING"},
+		{"abs(-9223372036854775807 - 1)", "integer overflow in `abs`"},
+		{"min()", "wrong number of arguments to `min`. got=0, want at least 2"},
+		{"max(1)", "wrong number of arguments to `max`. got=1, want at least 2"},
+		{`min(1, "2")`, "argument 2 to `min` must be FLOAT or INTEGER, got STRING"},
+		{"max(true, 1)", "argument 1 to `max` must be FLOAT or INTEGER, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestRangeBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []int64
+	}{
+		{"range(5)", []int64{0, 1, 2, 3, 4}},
+		{"range(0)", []int64{}},
+		{"range(-2)", []int64{}},
+		{"range(2, 5)", []int64{2, 3, 4}},
+		{"range(5, 2)", []int64{}},
+		{"range(0, 10, 2)", []int64{0, 2, 4, 6, 8}},
+		{"range(0, 9, 3)", []int64{0, 3, 6}},
+		{"range(3, 0, -1)", []int64{3, 2, 1}},
+		{"range(10, 0, -4)", []int64{10, 6, 2}},
+		{"range(0, 3, -1)", []int64{}},
+	}
+
+	for _, tt := range tests {
+		testIntegerArray(t, testEval(tt.input), tt.expected)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"range()", "wrong number of arguments. got=0, want=1, 2 or 3"},
+		{"range(1, 2, 3, 4)", "wrong number of arguments. got=4, want=1, 2 or 3"},
+		{"range(0, 5, 0)", "step passed to `range` must not be zero"},
+		{`range("5")`, "argument 1 to `range` must be INTEGER, got STRING"},
+		{"range(0, 2.5)", "argument 2 to `range` must be INTEGER, got FLOAT"},
+	}
+
+	for _, tt := range errors {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int("42")`, 42},
+		{`int("-7")`, -7},
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{"int(5)", 5},
+		{`int(str(12)) + 1`, 13},
+		{`str(42)`, "42"},
+		{`str(true)`, "true"},
+		{`str(2.5)`, "2.5"},
+		{`str("hi")`, "hi"},
+		{`str(null)`, "null"},
+		{`str([1, 2])`, "[1, 2]"},
+		{`str(["a", [1, "b"]])`, `["a", [1, "b"]]`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`int("4x")`, `cannot convert "4x" to INTEGER`},
+		{`int("")`, `cannot convert "" to INTEGER`},
+		{`int("99999999999999999999")`, `cannot convert "99999999999999999999" to INTEGER`},
+		{"int(true)", "argument to `int` must be STRING, FLOAT or INTEGER, got BOOLEAN"},
+		{"int()", "wrong number of arguments. got=0, want=1"},
+		{"str(1, 2)", "wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range errors {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDestructureStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a", 1},
+		{"let [a, b] = [1, 2]; b", 2},
+		{"let [a, b] = [1, 2]; let [a, b] = [b, a]; a * 10 + b", 21},
+		{"let [_, second, _] = [1, 2, 3]; second", 2},
+		{"let pair = fn() { [3, 4] }; let [x, y] = pair(); x * y", 12},
+		{"let [a, b] = [1]", "wrong number of values to destructure. got=1, want=2"},
+		{"let [a] = [1, 2]", "wrong number of values to destructure. got=2, want=1"},
+		{"let [a, b] = 5", "cannot destructure INTEGER, want ARRAY"},
+		{"let [a] = missing", "identifier not found: missing"},
+		{"const [a, b] = [1, 2]; a = 3", "cannot assign to constant 'a'"},
+		{"const a = 1; let [b, a] = [2, 3]", "cannot redeclare constant 'a'"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestMultiLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = 1, b = 2, c = 3; a + b + c", 6},
+		{"let a = 2, b = a * 3; b", 6},
+		{"let a = 1, b = a + 1, c = a + b; c", 3},
+		{"let f = fn(x) { x * 2 }, y = f(4); y", 8},
+		{"let g = fn() { 1 }, h = g; h()", 1},
+		{"for (let i = 0, n = 3; i < n; i++) { } 1", 1},
+		{"const a = 1, b = 2; a + b", 3},
+		{"const a = 1, b = 2; b = 3", "cannot assign to constant 'b'"},
+		{"let a = 1, b = missing, c = 3", "identifier not found: missing"},
+		{"const a = 1; let b = 2, a = 3", "cannot redeclare constant 'a'"},
+		{"let a = b, b = 1", "identifier not found: b"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+
+	// The bindings before an error are kept, the ones after aren't made
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New("let a = 1, b = -true, c = 3")).ParseProgram()
+	testErrorObject(t, Eval(program, env), "unknown operator: -BOOLEAN")
+	if _, ok := env.Get("a"); !ok {
+		t.Errorf("a was not bound")
+	}
+	if _, ok := env.Get("c"); ok {
+		t.Errorf("c was bound after the error")
+	}
+}
+
+func TestIndexAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let h = {}; h["a"] = 1; h["a"]`, 1},
+		{`let h = {"a": 1}; h["a"] = 2; h["a"]`, 2},
+		{`let h = {}; h[1] = 10; h[true] = 20; h[1] + h[true]`, 30},
+		{`let h = {}; h["a"] = 5`, 5},
+		{`let h = {"n": 1}; h["n"] += 4; h["n"]`, 5},
+		{"let xs = [1, 2, 3]; xs[0] = 10; xs[0] + xs[2]", 13},
+		{"let xs = [1, 2, 3]; xs[-1] = 9; xs[2]", 9},
+		{"let m = [[1, 2], [3, 4]]; m[1][0] = 7; m[1][0]", 7},
+		{"let xs = [1, 2]; let f = fn() { xs[1] = 5 }; f(); xs[1]", 5},
+		// Arrays are shared, assigning through one name is seen through the other
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", 2},
+		{"let a = [1]; let b = clone(a); b[0] = 2; a[0]", 1},
+		{"let xs = [1, 2]; xs[2] = 3", "index out of range: 2, length 2"},
+		{"let xs = [1, 2]; xs[-3] = 3", "index out of range: -3, length 2"},
+		{"let xs = []; xs[0] = 1", "index out of range: 0, length 0"},
+		{`let xs = [1]; xs["a"] = 1`, "index assignment not supported: ARRAY[STRING]"},
+		{`let h = {}; h[[1]] = 1`, "unusable as hash key: ARRAY"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING[INTEGER]"},
+		{"missing[0] = 1", "identifier not found: missing"},
+		{"let xs = [1]; xs[0] = missing", "identifier not found: missing"},
+		{"let xs = [2, 3]; xs[1] *= 4; xs[1]", 12},
+		// The container and index of a compound assignment are evaluated once
+		{"let n = 0; let f = fn() { n += 1; 0 }; let xs = [1]; xs[f()] += 5; n", 1},
+		{"let n = 0; let f = fn() { n += 1; 0 }; let xs = [1]; xs[f()] += 5; xs[0]", 6},
+		{"let n = 0; let xs = [1]; let g = fn() { n += 1; xs }; g()[0] += 1; n", 1},
+		{`let h = {}; h["n"] += 1`, "unknown operator: NULL + INTEGER"},
+		{`let s = "abc"; s[0] += "x"`, "index assignment not supported: STRING[INTEGER]"},
+		{"let xs = [1]; xs[0] += missing", "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestDiscardIdentifier(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let _ = 5; 1", 1},
+		{"let f = fn(_, y) { y }; f(1, 2)", 2},
+		{"let f = fn(_, _) { 3 }; f(1, 2)", 3},
+		{"_ = 4", 4},
+		{"let x = 1; let _ = fn() { x = 2 }(); x", 2},
+		{"_", "'_' is not readable"},
+		{"let _ = 5; _", "'_' is not readable"},
+		{"let f = fn(_) { _ }; f(1)", "'_' is not readable"},
+		{"let _ = y", "identifier not found: y"},
+		// The pipe placeholder is still substituted for the piped value
+		{"let f = fn(a, b) { a - b }; 10 |> f(_, 3)", 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestExponentiation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"2 ** 10", 1024},
+		{"2 ** 0", 1},
+		{"0 ** 0", 1},
+		{"(-3) ** 3", -27},
+		{"-3 ** 2", -9},
+		{"-5 ** 2", -25},
+		{"2 ** 3 ** 2", 512},
+		{"3 * 2 ** 2", 12},
+		{"let n = 5; n ** 2", 25},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testFloatObject(t, testEval("2.0 ** 3"), 8)
+	testFloatObject(t, testEval("4 ** 0.5"), 2)
+
+	testErrorObject(t, testEval("2 ** -1"), "negative exponent: 2 ** -1")
+	testErrorObject(t, testEval("let e = -2; 3 ** e"), "negative exponent: 3 ** -2")
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 5; x += 3; x", 8},
+		{"let x = 5; x -= 3; x", 2},
+		{"let x = 5; x *= 3; x", 15},
+		{"let x = 15; x /= 3; x", 5},
+		{"let x = 1; x += 2", 3},
+		{"let sum = 0; for (let i = 1; i < 4; i += 1) { sum += i }; sum", 6},
+		{`let s = "foo"; s += "bar"; s`, "foobar"},
+		{"y += 1", "identifier not found: y"},
+		{"const c = 1; c += 1", "cannot assign to constant 'c'"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				testErrorObject(t, errObj, expected)
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestUpdateExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 1; ++i", 2},
+		{"let i = 1; ++i; i", 2},
+		{"let i = 1; --i; --i; i", -1},
+		// Postfix evaluates to the value before the update
+		{"let i = 1; i++", 1},
+		{"let i = 1; i++; i", 2},
+		{"let i = 1; i--; i", 0},
+		{"let i = 0; let f = fn() { ++i }; f(); f(); i", 2},
+		{"let n = 0; for (let i = 0; i < 4; i++) { n++ }; n", 4},
+		{"++x", "identifier not found: x"},
+		{`let s = "a"; ++s`, "unknown operator: ++STRING"},
+		{"let b = true; b--", "unknown operator: --BOOLEAN"},
+		{"const c = 1; ++c", "cannot assign to constant 'c'"},
+		{"++puts", "cannot assign to builtin 'puts'"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestNullLiteral(t *testing.T) {
+	if result := testEval("null"); result != NULL {
+		t.Errorf("null is not NULL. got=%T (%+v)", result, result)
+	}
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = null; x == null", true},
+		{"null == null", true},
+		{"null != null", false},
+		{"if (false) { 1 } == null", true},
+		{"0 == null", false},
+		{"!null", true},
+		{"isNull(null)", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMinusPrefixOperator(t *testing.T) {
+	testIntegerObject(t, testEval("-5"), -5)
+	testFloatObject(t, testEval("-3.5"), -3.5)
+	testFloatObject(t, testEval("-(-3.5)"), 3.5)
+	testFloatObject(t, testEval("2 ** -1.0"), 0.5)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-true", "unknown operator: -BOOLEAN"},
+		{`-"a"`, "unknown operator: -STRING"},
+		{"-null", "unknown operator: -NULL"},
+		{"-[1]", "unknown operator: -ARRAY"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestChainedComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 < 2 < 3", "cannot compare BOOLEAN with INTEGER"},
+		{"3 > 2 > 1", "cannot compare BOOLEAN with INTEGER"},
+		{"1 < (2 < 3)", "cannot compare INTEGER with BOOLEAN"},
+		{"let x = 5; 1 < x < 10", "cannot compare BOOLEAN with INTEGER"},
+		{`1 < "2"`, "cannot compare INTEGER with STRING"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+
+	// Numbers of either kind still compare with each other
+	testBooleanObject(t, testEval("1 < 2.5"), true)
+	testBooleanObject(t, testEval("1 < 2 == true"), true)
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []string{
+		"10 / 0",
+		"1 / 0 + 1",
+		"let x = 0; 5 / x",
+		"let f = fn(n) { 100 / n }; f(0)",
+		"if (1 / 0 > 1) { 1 }",
+		"5 % 0",
+		"let x = 0; 5 % x",
+	}
+
+	for _, input := range tests {
+		testErrorObject(t, testEval(input), "division by zero")
+	}
+
+	// Floats follow IEEE 754 instead
+	testFloatObject(t, testEval("1.0 / 0"), math.Inf(1))
+}
@@ -0,0 +1,127 @@
+package evaluator
+
+import (
+	"sugiru/ast"
+	"sugiru/object"
+)
+
+// literal is the value of a folded constant, either an integer or a boolean
+type literal struct {
+	isBool bool
+	i      int64
+	b      bool
+}
+
+// evalLiteral is a fast path for expressions built only from integer and
+// boolean literals combined by operators, e.g. `(1 + 2) * 3 == 9`. The
+// whole tree is computed on plain Go values, allocating a single object
+// for the result. ok is false when the tree holds anything else, or
+// anything the fast path leaves to the general evaluator ( such as a
+// division by zero ), in which case the expression must be evaluated normally.
+func evalLiteral(exp ast.Expression) (object.Object, bool) {
+	lit, ok := foldLiteral(exp)
+	if !ok {
+		return nil, false
+	}
+
+	if lit.isBool {
+		return nativeBoolToBooleanObject(lit.b), true
+	}
+	return &object.Integer{Value: lit.i}, true
+}
+
+func foldLiteral(exp ast.Expression) (literal, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return literal{i: exp.Value}, true
+
+	case *ast.Boolean:
+		return literal{isBool: true, b: exp.Value}, true
+
+	case *ast.PrefixExpression:
+		right, ok := foldLiteral(exp.Right)
+		if !ok {
+			return literal{}, false
+		}
+		return foldPrefix(exp.Operator, right)
+
+	case *ast.InfixExpression:
+		left, ok := foldLiteral(exp.Left)
+		if !ok {
+			return literal{}, false
+		}
+		right, ok := foldLiteral(exp.Right)
+		if !ok {
+			return literal{}, false
+		}
+		return foldInfix(exp.Operator, left, right)
+	}
+
+	return literal{}, false
+}
+
+// foldPrefix mirrors evalPrefixExpression for the operands it accepts
+func foldPrefix(operator string, right literal) (literal, bool) {
+	switch {
+	case operator == "!" && right.isBool:
+		return literal{isBool: true, b: !right.b}, true
+	case operator == "!":
+		// Every integer is truthy
+		return literal{isBool: true, b: false}, true
+	case operator == "-" && !right.isBool:
+		return literal{i: -right.i}, true
+	case operator == "+" && !right.isBool:
+		return right, true
+	}
+	return literal{}, false
+}
+
+// foldInfix mirrors evalIntegerInfixExpression, and the equality of booleans
+func foldInfix(operator string, left literal, right literal) (literal, bool) {
+	if left.isBool && right.isBool {
+		switch operator {
+		case "==":
+			return literal{isBool: true, b: left.b == right.b}, true
+		case "!=":
+			return literal{isBool: true, b: left.b != right.b}, true
+		}
+		return literal{}, false
+	}
+
+	if left.isBool || right.isBool {
+		return literal{}, false
+	}
+
+	switch operator {
+	case "+":
+		return literal{i: left.i + right.i}, true
+	case "-":
+		return literal{i: left.i - right.i}, true
+	case "*":
+		return literal{i: left.i * right.i}, true
+	case "**":
+		if right.i < 0 {
+			return literal{}, false
+		}
+		return literal{i: intPow(left.i, right.i)}, true
+	case "/":
+		if right.i == 0 {
+			return literal{}, false
+		}
+		return literal{i: left.i / right.i}, true
+	case "%":
+		if right.i == 0 {
+			return literal{}, false
+		}
+		return literal{i: left.i % right.i}, true
+	case "<":
+		return literal{isBool: true, b: left.i < right.i}, true
+	case ">":
+		return literal{isBool: true, b: left.i > right.i}, true
+	case "==":
+		return literal{isBool: true, b: left.i == right.i}, true
+	case "!=":
+		return literal{isBool: true, b: left.i != right.i}, true
+	}
+	return literal{}, false
+}
@@ -1,7 +1,9 @@
 package lexer
 
 import (
+	"strings"
 	"sugiru/token"
+	"unicode/utf16"
 )
 
 type Lexer struct {
@@ -9,34 +11,88 @@ type Lexer struct {
 	position     int  // Current position in input (points to current character)
 	readPosition int  // Current reading position in input (after current char)
 	ch           byte // Current char under examination
+	line         int  // Line of the current char
+	column       int  // Column of the current char
+	options      Options
+}
+
+// Options change what the lexer produces
+type Options struct {
+	// Comments are produced as COMMENT tokens instead of being skipped,
+	// the literal being the whole comment starting at the `//`
+	Comments bool
+}
+
+// NewWithOptions creates a new lexer with the given options
+func NewWithOptions(input string, options Options) *Lexer {
+	l := New(input)
+	l.options = options
+	return l
 }
 
 // New creates a new lexer struct.
 func New(input string) *Lexer {
 	// Creates a new lexer
-	l := &Lexer{input: input}
+	l := &Lexer{}
 
 	// Initialize positional values etc.
 	// (ch -> first character )
 	// ( position -> 0 )
 	// ( readPosition -> 1 )
-	l.readChar()
+	l.Reset(input)
 
 	return l
 }
 
+// Reset makes the lexer start over on a new input, so a single
+// lexer can be reused across many snippets
+func (l *Lexer) Reset(input string) {
+	l.input = input
+	l.position = 0
+	l.readPosition = 0
+
+	// Clearing ch keeps readChar from counting a trailing newline
+	// of the previous input as the start of a new line
+	l.ch = 0
+	l.line = 1
+	l.column = 0
+
+	l.readChar()
+}
+
 func (l *Lexer) readChar() {
+	// Keep track of where the next character sits
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 1
+	} else {
+		l.column += 1
+	}
+
 	// Check for EOF
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+
+		// Stay at the end, however many more times we're asked to read,
+		// so slicing from the position never goes out of range
+		l.position = len(l.input)
+		l.readPosition = len(l.input) + 1
+		return
 	}
+
+	l.ch = l.input[l.readPosition]
+
 	// Advance to next character
 	l.position = l.readPosition
 	l.readPosition += 1
 }
 
+// atEOF returns whether the whole input has been consumed, a NUL byte
+// within the input is not the end of it even though ch is 0 for both
+func (l *Lexer) atEOF() bool {
+	return l.position >= len(l.input)
+}
+
 func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{
 		Type:    tokenType,
@@ -44,6 +100,20 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 	}
 }
 
+// Tokens drains the lexer, returning every remaining token. The final
+// token is always the EOF token.
+func (l *Lexer) Tokens() []token.Token {
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
 // NextToken returns the next token
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
@@ -52,6 +122,9 @@ func (l *Lexer) NextToken() token.Token {
 	// valid readable character is found
 	l.skipWhiteSpace()
 
+	// The token begins at the current character
+	line, column := l.line, l.column
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -62,9 +135,29 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '+' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.INCR, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.PLUS_EQ, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '-' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.DECR, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.MINUS_EQ, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -74,17 +167,74 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.POW, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.ASTERISK_EQ, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '/' {
+			// Only reached when comments are kept, otherwise they're skipped
+			tok.Type = token.COMMENT
+			tok.Literal = l.readComment()
+			tok.Line, tok.Column = line, column
+			return tok
+		} else if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SLASH_EQ, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.PIPE, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '|' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.OR, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.AND, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
 		tok = newToken(token.GT, l.ch)
+	case '.':
+		// Only three dots in a row mean something, a lone dot is illegal
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '?':
+		tok = newToken(token.QUESTION, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -93,18 +243,44 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case '"':
+		start := l.position
+		value, bad := l.readString()
+		if l.ch != '"' {
+			// Reaching EOF first makes the whole rest of the input illegal
+			tok.Type = token.ILLEGAL
+			tok.Literal = l.input[start:]
+			tok.Reason = "unterminated string"
+		} else if bad != "" {
+			// The whole string is illegal, reported by its bad escape
+			tok.Type = token.ILLEGAL
+			tok.Literal = bad
+			tok.Reason = "invalid escape sequence " + bad + " in string"
+		} else {
+			tok.Type = token.STRING
+			tok.Literal = value
+		}
 	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
+		if l.atEOF() {
+			tok.Literal = ""
+			tok.Type = token.EOF
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	default:
 		if isLetter(l.ch) { // Found a letter, we scan the identifier
 			tok.Literal = l.readIdentifier()          // Read the identifier
 			tok.Type = token.LookupIdent(tok.Literal) // Look up the identifier to get the appropriate token
+			tok.Line, tok.Column = line, column
 			return tok
 
 		} else if isDigit(l.ch) { // Found a digit, we scan the number
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Line, tok.Column = line, column
 			return tok
 
 		} else { // What even is this
@@ -112,6 +288,8 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 
+	tok.Line, tok.Column = line, column
+
 	// Advance to next character
 	l.readChar()
 	return tok
@@ -142,8 +320,9 @@ func (l *Lexer) readIdentifier() string {
 	return l.fromPosToCurrent(position)
 }
 
-// Return the number as a string ( Note: fractions not currently supported )
-func (l *Lexer) readNumber() string {
+// Return the number as a string along with its type, a number with a
+// fractional part ( digits '.' digits ) is a FLOAT, otherwise an INT
+func (l *Lexer) readNumber() (token.TokenType, string) {
 
 	// Mark the beginning of the lexeme
 	position := l.position
@@ -153,8 +332,118 @@ func (l *Lexer) readNumber() string {
 		l.readChar()
 	}
 
+	// A dot only belongs to the number if a digit follows it
+	if l.ch != '.' || !isDigit(l.peekChar()) {
+		return token.INT, l.fromPosToCurrent(position)
+	}
+
+	// Consume the dot and the fractional digits
+	l.readChar()
+	for isDigit(l.ch) {
+		l.readChar()
+	}
+
 	// Return the slice
-	return l.fromPosToCurrent(position)
+	return token.FLOAT, l.fromPosToCurrent(position)
+}
+
+// readString returns the decoded contents of a string literal, the current
+// character is the opening quote and is left on the closing quote, or at EOF
+// when there is none. When the string holds an unknown escape sequence the
+// first one is returned as bad.
+func (l *Lexer) readString() (value string, bad string) {
+	var out strings.Builder
+
+	// Consume until the closing quote (or EOF for an unterminated string)
+	for {
+		l.readChar()
+		if l.ch == '"' || l.atEOF() {
+			break
+		}
+
+		if l.ch != '\\' {
+			out.WriteByte(l.ch)
+			continue
+		}
+
+		// Decode the character following the backslash
+		l.readChar()
+		switch l.ch {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '"':
+			out.WriteByte('"')
+		case '\\':
+			out.WriteByte('\\')
+		case 'x':
+			// \xHH is the byte with the hexadecimal value HH
+			if value, literal, ok := l.readHexEscape(2); ok {
+				out.WriteByte(byte(value))
+			} else if bad == "" {
+				bad = literal
+			}
+		case 'u':
+			// \uHHHH is the UTF-8 encoding of the code point U+HHHH,
+			// the surrogate halves aren't code points of their own
+			if value, literal, ok := l.readHexEscape(4); ok && !utf16.IsSurrogate(rune(value)) {
+				out.WriteRune(rune(value))
+			} else if bad == "" {
+				bad = literal
+			}
+		case 0:
+			if l.atEOF() {
+				// Unterminated right after the backslash
+				return out.String(), bad
+			}
+			if bad == "" {
+				bad = "\\" + string(l.ch)
+			}
+		default:
+			if bad == "" {
+				bad = "\\" + string(l.ch)
+			}
+		}
+	}
+
+	return out.String(), bad
+}
+
+// readHexEscape reads the n hexadecimal digits following the current `x` or
+// `u` of an escape sequence. When one is missing ok is false, and the digits
+// before it are left consumed as part of the bad escape. The literal is the
+// escape sequence as written, starting at the backslash.
+func (l *Lexer) readHexEscape(n int) (value int, literal string, ok bool) {
+	// The backslash sits right before the current character
+	position := l.position - 1
+
+	for i := 0; i < n; i++ {
+		digit, isHex := hexValue(l.peekChar())
+		if !isHex {
+			return 0, l.input[position : l.position+1], false
+		}
+
+		l.readChar()
+		value = value*16 + digit
+	}
+
+	return value, l.input[position : l.position+1], true
+}
+
+// hexValue returns the value of a hexadecimal digit, in either case
+func hexValue(ch byte) (int, bool) {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0'), true
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10, true
+	case 'A' <= ch && ch <= 'F':
+		return int(ch-'A') + 10, true
+	}
+	return 0, false
 }
 
 // skipWhiteSpace consumes characters as long as it is a white space character
@@ -163,12 +452,30 @@ func (l *Lexer) skipWhiteSpace() {
 		switch l.ch {
 		case ' ', '\t', '\n', '\r':
 			l.readChar()
+		case '/':
+			// Comments are skipped along with the white space, unless they're kept
+			if l.peekChar() != '/' || l.options.Comments {
+				return
+			}
+			l.readComment()
 		default:
 			return
 		}
 	}
 }
 
+// readComment returns the comment starting at the current `//`, which runs
+// until the end of the line. The current character is left on the newline.
+func (l *Lexer) readComment() string {
+	position := l.position
+
+	for l.ch != '\n' && !l.atEOF() {
+		l.readChar()
+	}
+
+	return l.fromPosToCurrent(position)
+}
+
 // extractFromPosToCurrent retrieves a slice of the input, starting from position to current index
 func (l *Lexer) fromPosToCurrent(position int) string {
 	return l.input[position:l.position]
@@ -25,6 +25,13 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+"foobar"
+"foo bar"
+3.14 10.0
+[1, 2];
+x |> f
+{"foo": "bar"}
+a ? b : c
 `
 
 	tests := []struct {
@@ -104,6 +111,29 @@ if (5 < 10) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "10.0"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "a"},
+		{token.QUESTION, "?"},
+		{token.IDENT, "b"},
+		{token.COLON, ":"},
+		{token.IDENT, "c"},
 		{token.EOF, ""},
 	}
 
@@ -123,3 +153,413 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + 10
+"str" 1.5`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"x", 2, 3},
+		{"+", 2, 5},
+		{"10", 2, 7},
+		{"str", 3, 1},
+		{"1.5", 3, 7},
+		{"", 3, 10},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	expected := []token.Token{
+		{Type: token.LET, Literal: "let", Line: 1, Column: 1},
+		{Type: token.IDENT, Literal: "x", Line: 1, Column: 5},
+		{Type: token.ASSIGN, Literal: "=", Line: 1, Column: 7},
+		{Type: token.INT, Literal: "5", Line: 1, Column: 9},
+		{Type: token.SEMICOLON, Literal: ";", Line: 1, Column: 10},
+		{Type: token.IDENT, Literal: "x", Line: 2, Column: 1},
+		{Type: token.PLUS, Literal: "+", Line: 2, Column: 3},
+		{Type: token.STRING, Literal: "a", Line: 2, Column: 5},
+		{Type: token.EOF, Literal: "", Line: 2, Column: 8},
+	}
+
+	tokens := New("let x = 5;\nx + \"a\"").Tokens()
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d (%+v)", len(expected), len(tokens), tokens)
+	}
+	for i, tok := range tokens {
+		if tok != expected[i] {
+			t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], tok)
+		}
+	}
+
+	// An empty input still ends with EOF
+	if tokens := New("").Tokens(); len(tokens) != 1 || tokens[0].Type != token.EOF {
+		t.Errorf("empty input should give only EOF. got=%+v", tokens)
+	}
+}
+
+func TestReset(t *testing.T) {
+	l := New("let a = 1;\n")
+	l.Tokens()
+
+	l.Reset("b + 2")
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "b", Line: 1, Column: 1},
+		{Type: token.PLUS, Literal: "+", Line: 1, Column: 3},
+		{Type: token.INT, Literal: "2", Line: 1, Column: 5},
+		{Type: token.EOF, Literal: "", Line: 1, Column: 6},
+	}
+
+	tokens := l.Tokens()
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d (%+v)", len(expected), len(tokens), tokens)
+	}
+	for i, tok := range tokens {
+		if tok != expected[i] {
+			t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], tok)
+		}
+	}
+
+	// Resetting part way through an input drops the rest of it
+	l.Reset("x y z")
+	l.NextToken()
+	l.Reset("fn")
+	if tok := l.NextToken(); tok.Type != token.FUNCTION || tok.Column != 1 {
+		t.Errorf("expected FUNCTION at column 1. got=%+v", tok)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"line1\nline2"`, token.STRING, "line1\nline2"},
+		{`"tab\tend"`, token.STRING, "tab\tend"},
+		{`"cr\r"`, token.STRING, "cr\r"},
+		{`"quote:\""`, token.STRING, `quote:"`},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"\\\""`, token.STRING, `\"`},
+		{`"no escapes"`, token.STRING, "no escapes"},
+		{`"bad \q escape"`, token.ILLEGAL, `\q`},
+		{`"\n then \x and \y"`, token.ILLEGAL, `\x`},
+		{`"\x41"`, token.STRING, "A"},
+		{`"\x4a\x4A!"`, token.STRING, "JJ!"},
+		{`"nul\x00"`, token.STRING, "nul\x00"},
+		{`"\x414"`, token.STRING, "A4"},
+		{`"\u00e9"`, token.STRING, "é"},
+		{`"caf\u00E9s"`, token.STRING, "cafés"},
+		{`"\u65e5\u672c"`, token.STRING, "日本"},
+		{`"\u00e9\x41"`, token.STRING, "éA"},
+		{`"\x4"`, token.ILLEGAL, `\x4`},
+		{`"\x4g"`, token.ILLEGAL, `\x4`},
+		{`"\xZZ"`, token.ILLEGAL, `\x`},
+		{`"\u00e"`, token.ILLEGAL, `\u00e`},
+		{`"\u12"`, token.ILLEGAL, `\u12`},
+		{`"\uzzzz"`, token.ILLEGAL, `\u`},
+		{`"\ud800"`, token.ILLEGAL, `\ud800`},
+		{`"ok \x41 then \u12 and \q"`, token.ILLEGAL, `\u12`},
+		// Unterminated strings are illegal as a whole, even with a bad escape
+		{`"abc`, token.ILLEGAL, `"abc`},
+		{`"bad \q`, token.ILLEGAL, `"bad \q`},
+		{`"ends in \"`, token.ILLEGAL, `"ends in \"`},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		// The whole literal is consumed, even when it is illegal
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("tests[%d] - expected EOF after the string. got=%+v", i, next)
+		}
+	}
+}
+
+func TestPowToken(t *testing.T) {
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "2"},
+		{token.POW, "**"},
+		{token.INT, "3"},
+		{token.ASTERISK, "*"},
+		{token.INT, "4"},
+		{token.EOF, ""},
+	}
+
+	l := New("2 ** 3 * 4")
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestPercentToken(t *testing.T) {
+	expected := []token.TokenType{token.INT, token.PERCENT, token.IDENT, token.EOF}
+
+	for i, tok := range New("7 % n").Tokens() {
+		if tok.Type != expected[i] {
+			t.Fatalf("tokens[%d] - wrong type. expected=%s, got=%s", i, expected[i], tok.Type)
+		}
+	}
+}
+
+func TestLogicalTokens(t *testing.T) {
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.AND, Literal: "&&"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.OR, Literal: "||"},
+		{Type: token.IDENT, Literal: "c"},
+		{Type: token.ILLEGAL, Literal: "&"},
+		{Type: token.PIPE, Literal: "|>"},
+		{Type: token.ILLEGAL, Literal: "|"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	tokens := New("a && b || c & |> |").Tokens()
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tok := range tokens {
+		if tok.Type != expected[i].Type || tok.Literal != expected[i].Literal {
+			t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], tok)
+		}
+	}
+}
+
+func TestEllipsisToken(t *testing.T) {
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "f"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.ELLIPSIS, Literal: "..."},
+		{Type: token.IDENT, Literal: "xs"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.ILLEGAL, Literal: "."},
+		{Type: token.ILLEGAL, Literal: "."},
+		{Type: token.ELLIPSIS, Literal: "..."},
+		{Type: token.ILLEGAL, Literal: "."},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	tokens := New("f(...xs) .. ....").Tokens()
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tok := range tokens {
+		if tok.Type != expected[i].Type || tok.Literal != expected[i].Literal {
+			t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], tok)
+		}
+	}
+}
+
+func TestCompoundAssignTokens(t *testing.T) {
+	expected := []token.TokenType{
+		token.IDENT, token.PLUS_EQ, token.INT,
+		token.IDENT, token.MINUS_EQ, token.INT,
+		token.IDENT, token.ASTERISK_EQ, token.INT,
+		token.IDENT, token.SLASH_EQ, token.INT,
+		token.IDENT, token.PLUS, token.ASSIGN, token.INT,
+		token.EOF,
+	}
+
+	tokens := New("a += 1 b -= 2 c *= 3 d /= 4 e + = 5").Tokens()
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tok := range tokens {
+		if tok.Type != expected[i] {
+			t.Errorf("tokens[%d] - tokentype wrong. expected=%q, got=%q", i, expected[i], tok.Type)
+		}
+	}
+}
+
+func TestIncrementTokens(t *testing.T) {
+	expected := []token.TokenType{
+		token.INCR, token.IDENT, token.IDENT, token.DECR,
+		token.MINUS, token.MINUS_EQ, token.PLUS, token.PLUS_EQ,
+		token.EOF,
+	}
+
+	tokens := New("++a b-- - -= + +=").Tokens()
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tok := range tokens {
+		if tok.Type != expected[i] {
+			t.Errorf("tokens[%d] - tokentype wrong. expected=%q, got=%q", i, expected[i], tok.Type)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// leading\nlet x = 10 / 2; // trailing\n//\nx"
+
+	// By default comments are skipped like white space
+	skipped := []token.Token{
+		{Type: token.LET, Literal: "let", Line: 2, Column: 1},
+		{Type: token.IDENT, Literal: "x", Line: 2, Column: 5},
+		{Type: token.ASSIGN, Literal: "=", Line: 2, Column: 7},
+		{Type: token.INT, Literal: "10", Line: 2, Column: 9},
+		{Type: token.SLASH, Literal: "/", Line: 2, Column: 12},
+		{Type: token.INT, Literal: "2", Line: 2, Column: 14},
+		{Type: token.SEMICOLON, Literal: ";", Line: 2, Column: 15},
+		{Type: token.IDENT, Literal: "x", Line: 4, Column: 1},
+		{Type: token.EOF, Literal: "", Line: 4, Column: 2},
+	}
+
+	// When kept, each comment is a token of its own
+	kept := []token.Token{
+		{Type: token.COMMENT, Literal: "// leading", Line: 1, Column: 1},
+		{Type: token.LET, Literal: "let", Line: 2, Column: 1},
+		{Type: token.IDENT, Literal: "x", Line: 2, Column: 5},
+		{Type: token.ASSIGN, Literal: "=", Line: 2, Column: 7},
+		{Type: token.INT, Literal: "10", Line: 2, Column: 9},
+		{Type: token.SLASH, Literal: "/", Line: 2, Column: 12},
+		{Type: token.INT, Literal: "2", Line: 2, Column: 14},
+		{Type: token.SEMICOLON, Literal: ";", Line: 2, Column: 15},
+		{Type: token.COMMENT, Literal: "// trailing", Line: 2, Column: 17},
+		{Type: token.COMMENT, Literal: "//", Line: 3, Column: 1},
+		{Type: token.IDENT, Literal: "x", Line: 4, Column: 1},
+		{Type: token.EOF, Literal: "", Line: 4, Column: 2},
+	}
+
+	tests := []struct {
+		lexer    *Lexer
+		expected []token.Token
+	}{
+		{New(input), skipped},
+		{NewWithOptions(input, Options{}), skipped},
+		{NewWithOptions(input, Options{Comments: true}), kept},
+	}
+
+	for n, tt := range tests {
+		tokens := tt.lexer.Tokens()
+		if len(tokens) != len(tt.expected) {
+			t.Fatalf("tests[%d] - wrong number of tokens. expected=%d, got=%d (%+v)",
+				n, len(tt.expected), len(tokens), tokens)
+		}
+		for i, tok := range tokens {
+			if tok != tt.expected[i] {
+				t.Errorf("tests[%d] - tokens[%d] wrong. expected=%+v, got=%+v", n, i, tt.expected[i], tok)
+			}
+		}
+	}
+
+	// A comment running into the end of the input
+	l := NewWithOptions("1 // end", Options{Comments: true})
+	l.Reset("x//y")
+	tokens := l.Tokens()
+	if len(tokens) != 3 || tokens[1].Type != token.COMMENT || tokens[1].Literal != "//y" {
+		t.Errorf("comment at the end of the input wrong. got=%+v", tokens)
+	}
+}
+
+func TestMalformedInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		// A NUL byte within the input isn't the end of it
+		{"1\x002", []token.TokenType{token.INT, token.ILLEGAL, token.INT, token.EOF}},
+		{"\"a\x00b\"", []token.TokenType{token.STRING, token.EOF}},
+		{"\"a\\\x00\"", []token.TokenType{token.ILLEGAL, token.EOF}},
+		{"\"unterminated", []token.TokenType{token.ILLEGAL, token.EOF}},
+		{"\"trailing\\", []token.TokenType{token.ILLEGAL, token.EOF}},
+		{"1.", []token.TokenType{token.INT, token.ILLEGAL, token.EOF}},
+		{"|", []token.TokenType{token.ILLEGAL, token.EOF}},
+		{"\xff\xfe", []token.TokenType{token.ILLEGAL, token.ILLEGAL, token.EOF}},
+	}
+
+	for _, tt := range tests {
+		tokens := New(tt.input).Tokens()
+		if len(tokens) != len(tt.expected) {
+			t.Errorf("%q - wrong number of tokens. expected=%d, got=%d (%+v)",
+				tt.input, len(tt.expected), len(tokens), tokens)
+			continue
+		}
+		for i, tok := range tokens {
+			if tok.Type != tt.expected[i] {
+				t.Errorf("%q - tokens[%d] wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], tok.Type)
+			}
+		}
+	}
+
+	// Reading past the end keeps producing EOF
+	l := New("x")
+	l.NextToken()
+	for i := 0; i < 3; i++ {
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("expected EOF after the end, got=%+v", tok)
+		}
+	}
+}
+
+func FuzzLexer(f *testing.F) {
+	seeds := []string{
+		"",
+		"let five = 5; let add = fn(x, y) { x + y; };",
+		"\"hello \\n world\" \"bad \\q\" \"unterminated",
+		"1.5 ** 2 |> f % 3 // comment",
+		"{\"a\": [1, 2.]}; a[0] += 1; x++ --y",
+		"\x00\xff\"\\",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, options := range []Options{{}, {Comments: true}} {
+			l := NewWithOptions(input, options)
+
+			// Every token other than EOF consumes at least a byte
+			for i := 0; ; i++ {
+				if i > len(input) {
+					t.Fatalf("no EOF after %d tokens of %q", i, input)
+				}
+				if l.NextToken().Type == token.EOF {
+					break
+				}
+			}
+		}
+	})
+}
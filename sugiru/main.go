@@ -2,17 +2,219 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/user"
+	"strings"
+	"sugiru/checker"
+	"sugiru/evaluator"
+	"sugiru/lexer"
+	"sugiru/object"
+	"sugiru/parser"
 	"sugiru/repl"
 )
 
+// exitAssertion is the exit code of a program stopped by a failing `assert`,
+// telling failing test scripts apart from broken ones
+const exitAssertion = 3
+
+// version identifies the build, set at build time with
+// -ldflags "-X main.version=<version>"
+var version = "dev"
+
 func main() {
-	user, err := user.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run dispatches on the command line arguments and returns the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	// Anything the program prints goes to stdout
+	evaluator.Output = stdout
+
+	// -sandbox comes first and applies to whichever mode follows it
+	sandboxed := len(args) > 0 && args[0] == "-sandbox"
+	if sandboxed {
+		args = args[1:]
+	}
+
+	// No arguments, start the interactive REPL, unless the input
+	// is piped in, in which case it is run as a single program
+	if len(args) == 0 {
+		if !isTerminal(stdin) {
+			return runStdin(stdin, stderr, sandboxed)
+		}
+
+		user, err := user.Current()
+		if err != nil {
+			panic(err)
+		}
+
+		repl.StartWithConfig(stdin, stdout, repl.Config{
+			Prompt:    repl.PROMPT,
+			Banner:    fmt.Sprintf("[ SUGIRU REPL MODE : USER {%s} ]\n", user.Username),
+			Sandboxed: sandboxed,
+		})
+		return 0
+	}
+
+	switch args[0] {
+	case "-v", "--version", "version":
+		fmt.Fprintf(stdout, "sugiru %s\n", version)
+		return 0
+	case "-i":
+		repl.StartWithConfig(stdin, stdout, repl.Config{Indexed: true, Sandboxed: sandboxed})
+		return 0
+	case "-e":
+		if len(args) != 2 {
+			fmt.Fprintln(stderr, "usage: sugiru -e <expression>")
+			return 2
+		}
+		return evalExpression(args[1], stdout, stderr, sandboxed)
+	case "check":
+		if len(args) != 2 {
+			fmt.Fprintln(stderr, "usage: sugiru check <file>")
+			return 2
+		}
+		return checkFile(args[1], stdout, stderr)
+	default:
+		if strings.HasPrefix(args[0], "-") || len(args) != 1 {
+			fmt.Fprintf(stderr, "unknown argument: %s\n", args[0])
+			return 2
+		}
+		return runFile(args[0], stderr, sandboxed)
+	}
+}
+
+// isTerminal reports whether the input is an interactive terminal,
+// anything that isn't a character device is considered piped
+func isTerminal(stdin io.Reader) bool {
+	f, ok := stdin.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// runFile evaluates the whole file as a single program, unlike the REPL
+// the value of the program is not printed, only what `puts` writes out
+func runFile(path string, stderr io.Writer, sandboxed bool) int {
+	source, err := os.ReadFile(path)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(stderr, err)
+		return 1
 	}
 
-	fmt.Printf("[ SUGIRU REPL MODE : USER {%s} ]\n", user.Username)
-	repl.Start(os.Stdin, os.Stdout)
+	return runSource(path, string(source), stderr, sandboxed)
+}
+
+// runStdin reads everything piped in and runs it just like a file
+func runStdin(stdin io.Reader, stderr io.Writer, sandboxed bool) int {
+	source, err := io.ReadAll(stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return runSource("<stdin>", string(source), stderr, sandboxed)
+}
+
+// runSource evaluates the source as a single program, name is
+// used to prefix the parse errors
+func runSource(name string, source string, stderr io.Writer, sandboxed bool) int {
+	l := lexer.New(source)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		printParseErrors(stderr, name, source, p)
+		return 1
+	}
+
+	evaluated := evaluator.Eval(program, newEnvironment(sandboxed))
+	if err, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintln(stderr, err.Inspect())
+		if err.Assertion {
+			return exitAssertion
+		}
+		return 1
+	}
+
+	return 0
+}
+
+// evalExpression evaluates the source against a fresh environment
+// and prints the result
+func evalExpression(source string, stdout io.Writer, stderr io.Writer, sandboxed bool) int {
+	l := lexer.New(source)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		printParseErrors(stderr, "-e", source, p)
+		return 1
+	}
+
+	evaluated := evaluator.Eval(program, newEnvironment(sandboxed))
+	if evaluated == nil {
+		return 0
+	}
+
+	if evaluated.Type() == object.ERROR_OBJ {
+		fmt.Fprintln(stderr, evaluated.Inspect())
+		return 1
+	}
+
+	fmt.Fprintln(stdout, evaluated.Inspect())
+	return 0
+}
+
+// newEnvironment creates the top level environment of a program,
+// sandboxed when -sandbox was given
+func newEnvironment(sandboxed bool) *object.Environment {
+	if sandboxed {
+		return object.NewSandboxedEnvironment()
+	}
+	return object.NewEnvironment()
+}
+
+// checkFile parses the file and reports the diagnostics found in it
+func checkFile(path string, stdout io.Writer, stderr io.Writer) int {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	l := lexer.New(string(source))
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		printParseErrors(stderr, path, string(source), p)
+		return 1
+	}
+
+	for _, d := range checker.Check(program) {
+		fmt.Fprintf(stdout, "%s:%s\n", path, d)
+	}
+
+	return 0
+}
+
+// printParseErrors reports each parser error with its position,
+// followed by the offending line and a caret under the column
+func printParseErrors(stderr io.Writer, name string, source string, p *parser.Parser) {
+	for _, err := range p.ParseErrors() {
+		fmt.Fprintf(stderr, "%s:%s\n", name, err.Error())
+
+		if caret := parser.RenderCaret(source, err.Line, err.Column); caret != "" {
+			fmt.Fprintln(stderr, caret)
+		}
+	}
 }
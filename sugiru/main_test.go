@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeScript(t *testing.T, source string) string {
+	path := filepath.Join(t.TempDir(), "script.sg")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatalf("could not write script: %v", err)
+	}
+	return path
+}
+
+func TestRunFile(t *testing.T) {
+	path := writeScript(t, `let x = 5;
+puts(x);
+puts("done");
+x + 10;
+`)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{path}, strings.NewReader(""), &stdout, &stderr)
+
+	if code != 0 {
+		t.Fatalf("wrong exit code. got=%d, stderr=%q", code, stderr.String())
+	}
+	// The value of the last expression is not printed in file mode
+	if stdout.String() != "5\ndone\n" {
+		t.Errorf("wrong output. got=%q", stdout.String())
+	}
+}
+
+func TestRunFileParseErrors(t *testing.T) {
+	path := writeScript(t, "let = 5;")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{path}, strings.NewReader(""), &stdout, &stderr)
+
+	if code == 0 {
+		t.Errorf("expected a non-zero exit code")
+	}
+	if !strings.Contains(stderr.String(), "expected next token to be IDENT") {
+		t.Errorf("parser error not reported. got=%q", stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected no output. got=%q", stdout.String())
+	}
+}
+
+func TestVersion(t *testing.T) {
+	for _, arg := range []string{"-v", "--version", "version"} {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{arg}, strings.NewReader(""), &stdout, &stderr)
+
+		if code != 0 {
+			t.Errorf("%s: wrong exit code. got=%d", arg, code)
+		}
+		if stdout.String() != "sugiru dev\n" {
+			t.Errorf("%s: wrong output. got=%q", arg, stdout.String())
+		}
+	}
+}
+
+func TestEvalFlag(t *testing.T) {
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"-e", "1 + 2"}, 0, "3\n", ""},
+		{[]string{"-e", `let s = "a"; s + "b"`}, 0, "\"ab\"\n", ""},
+		{[]string{"-e", "let x 5"}, 1, "", "-e:1:7: expected next token to be =, got INT instead\nlet x 5\n      ^\n"},
+		{[]string{"-e", "missing + 1"}, 1, "", "ERROR: identifier not found: missing\n"},
+		{[]string{"-e"}, 2, "", "usage: sugiru -e <expression>\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+
+		if code != tt.code {
+			t.Errorf("%v: wrong exit code. want=%d, got=%d", tt.args, tt.code, code)
+		}
+		if stdout.String() != tt.stdout {
+			t.Errorf("%v: wrong stdout. want=%q, got=%q", tt.args, tt.stdout, stdout.String())
+		}
+		if stderr.String() != tt.stderr {
+			t.Errorf("%v: wrong stderr. want=%q, got=%q", tt.args, tt.stderr, stderr.String())
+		}
+	}
+}
+
+func TestRunPipedStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(nil, strings.NewReader("let x = 1 + 2;\nputs(x);\nx * 2;\n"), &stdout, &stderr)
+
+	if code != 0 {
+		t.Fatalf("wrong exit code. got=%d, stderr=%q", code, stderr.String())
+	}
+	// No banner, no prompts and no echoed values, just what `puts` wrote
+	if stdout.String() != "3\n" {
+		t.Errorf("wrong output. got=%q", stdout.String())
+	}
+}
+
+func TestRunPipedStdinErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(nil, strings.NewReader("let x 5;"), &stdout, &stderr)
+
+	if code == 0 {
+		t.Errorf("expected a non-zero exit code")
+	}
+	if !strings.HasPrefix(stderr.String(), "<stdin>:1:7:") {
+		t.Errorf("parser error not reported. got=%q", stderr.String())
+	}
+}
+
+func TestRunFileExitCodes(t *testing.T) {
+	tests := []struct {
+		source string
+		code   int
+		stderr string
+	}{
+		{"assert(1 + 1 == 2);\nputs(\"ok\");", 0, ""},
+		{"assert(1 == 2, \"one is two\");\nputs(\"unreachable\");", exitAssertion, "ERROR: assertion failed: one is two\n"},
+		{"missing;", 1, "ERROR: identifier not found: missing\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{writeScript(t, tt.source)}, strings.NewReader(""), &stdout, &stderr)
+
+		if code != tt.code {
+			t.Errorf("%q: wrong exit code. want=%d, got=%d", tt.source, tt.code, code)
+		}
+		if stderr.String() != tt.stderr {
+			t.Errorf("%q: wrong stderr. want=%q, got=%q", tt.source, tt.stderr, stderr.String())
+		}
+		if strings.Contains(stdout.String(), "unreachable") {
+			t.Errorf("%q: the program went on after the assertion", tt.source)
+		}
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"-sandbox", "-e", `eval("1 + 2")`}, 1, "", "ERROR: identifier not found: eval\n"},
+		{[]string{"-sandbox", writeScript(t, `puts(eval("1"));`)}, 1, "", "ERROR: identifier not found: eval\n"},
+		{[]string{"-sandbox", "-e", "abs(-2)"}, 0, "2\n", ""},
+		// Without the flag eval is there as usual
+		{[]string{"-e", `eval("1 + 2")`}, 0, "3\n", ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &stdout, &stderr)
+
+		if code != tt.code {
+			t.Errorf("%v: wrong exit code. want=%d, got=%d", tt.args, tt.code, code)
+		}
+		if stdout.String() != tt.stdout {
+			t.Errorf("%v: wrong stdout. want=%q, got=%q", tt.args, tt.stdout, stdout.String())
+		}
+		if stderr.String() != tt.stderr {
+			t.Errorf("%v: wrong stderr. want=%q, got=%q", tt.args, tt.stderr, stderr.String())
+		}
+	}
+
+	// Piped input is sandboxed too
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-sandbox"}, strings.NewReader(`eval("1")`), &stdout, &stderr)
+	if code != 1 || stderr.String() != "ERROR: identifier not found: eval\n" {
+		t.Errorf("piped input not sandboxed. code=%d, stderr=%q", code, stderr.String())
+	}
+}
@@ -0,0 +1,90 @@
+package object
+
+import "sort"
+
+// Environment holds the bindings of names to values
+type Environment struct {
+	store     map[string]Object // Allocated on the first Set, nil until then
+	constants map[string]bool   // Names in this scope bound with const
+	outer     *Environment      // The enclosing scope, nil at the top level
+	sandboxed bool              // Inherited by every scope nested inside
+}
+
+// NewEnvironment creates an empty environment
+func NewEnvironment() *Environment {
+	return &Environment{}
+}
+
+// NewSandboxedEnvironment creates an empty environment in which the builtins
+// letting a program reach past the code it was given, such as `eval`, are hidden
+func NewSandboxedEnvironment() *Environment {
+	return &Environment{sandboxed: true}
+}
+
+// NewEnclosedEnvironment creates an empty environment nested inside outer.
+// Calls which never bind anything ( e.g. a function without parameters
+// that only reads outer names ) never allocate a store.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	return &Environment{outer: outer, sandboxed: outer.sandboxed}
+}
+
+// Sandboxed returns whether the environment was created by
+// NewSandboxedEnvironment, or is nested inside one that was
+func (e *Environment) Sandboxed() bool {
+	return e.sandboxed
+}
+
+// Get retrieves the value bound to name, looking through
+// the enclosing scopes if it isn't bound in this one
+func (e *Environment) Get(name string) (Object, bool) {
+	// Reading a nil store finds nothing and falls through to outer
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set binds val to name in this scope, returning val
+func (e *Environment) Set(name string, val Object) Object {
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
+	e.store[name] = val
+	return val
+}
+
+// SetConst binds val to name in this scope as a constant, returning val
+func (e *Environment) SetConst(name string, val Object) Object {
+	if e.constants == nil {
+		e.constants = make(map[string]bool)
+	}
+	e.constants[name] = true
+	return e.Set(name, val)
+}
+
+// IsConst returns whether name is bound as a constant in this scope
+func (e *Environment) IsConst(name string) bool {
+	return e.constants[name]
+}
+
+// Names returns the names bound in this scope, not the enclosing ones, sorted
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Scope returns the nearest environment which binds name,
+// starting from this one, or nil if name isn't bound at all
+func (e *Environment) Scope(name string) *Environment {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			return env
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package object
+
+import "testing"
+
+func TestEnvironmentScoping(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+
+	inner := NewEnclosedEnvironment(outer)
+	if inner.store != nil {
+		t.Fatalf("enclosed environment allocated a store before any Set")
+	}
+
+	// Reads fall through to the outer scope
+	obj, ok := inner.Get("x")
+	if !ok || obj.(*Integer).Value != 1 {
+		t.Fatalf("inner.Get(x) wrong. got=%v, %t", obj, ok)
+	}
+	if _, ok := inner.Get("y"); ok {
+		t.Fatalf("inner.Get(y) found a binding which doesn't exist")
+	}
+	if inner.Scope("x") != outer {
+		t.Fatalf("x should be bound in the outer scope")
+	}
+
+	// Binding locally shadows without touching the outer scope
+	inner.Set("x", &Integer{Value: 2})
+	if obj, _ := inner.Get("x"); obj.(*Integer).Value != 2 {
+		t.Errorf("inner x wrong. got=%s", obj.Inspect())
+	}
+	if obj, _ := outer.Get("x"); obj.(*Integer).Value != 1 {
+		t.Errorf("outer x changed. got=%s", obj.Inspect())
+	}
+	if inner.Scope("x") != inner {
+		t.Errorf("x should now be bound in the inner scope")
+	}
+
+	// Constants work on a lazily allocated store as well
+	lazy := NewEnclosedEnvironment(outer)
+	lazy.SetConst("c", &Integer{Value: 3})
+	if !lazy.IsConst("c") || lazy.IsConst("x") {
+		t.Errorf("constants wrong. c=%t x=%t", lazy.IsConst("c"), lazy.IsConst("x"))
+	}
+}
+
+func TestNestedEnvironments(t *testing.T) {
+	global := NewEnvironment()
+	global.Set("a", &Integer{Value: 1})
+	global.Set("b", &Integer{Value: 2})
+
+	function := NewEnclosedEnvironment(global)
+	function.Set("b", &Integer{Value: 20})
+
+	block := NewEnclosedEnvironment(function)
+	block.Set("c", &Integer{Value: 300})
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		expected int64 // 0 when the name should not be found
+	}{
+		// Reads walk outward through every enclosing scope
+		{block, "a", 1},
+		{block, "b", 20},
+		{block, "c", 300},
+		{function, "a", 1},
+		{function, "b", 20},
+		{function, "c", 0},
+		// The shadowed binding is untouched
+		{global, "b", 2},
+		{global, "c", 0},
+	}
+
+	for _, tt := range tests {
+		obj, ok := tt.env.Get(tt.name)
+		if tt.expected == 0 {
+			if ok {
+				t.Errorf("%s should not be found. got=%s", tt.name, obj.Inspect())
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s not found", tt.name)
+			continue
+		}
+		if obj.(*Integer).Value != tt.expected {
+			t.Errorf("%s wrong. expected=%d, got=%s", tt.name, tt.expected, obj.Inspect())
+		}
+	}
+
+	// Set always binds in the scope it is called on
+	if got := block.Set("a", &Integer{Value: 1000}); got.(*Integer).Value != 1000 {
+		t.Errorf("Set should return the value. got=%s", got.Inspect())
+	}
+	if obj, _ := global.Get("a"); obj.(*Integer).Value != 1 {
+		t.Errorf("Set changed an outer scope. got=%s", obj.Inspect())
+	}
+	if block.Scope("a") != block || function.Scope("a") != global {
+		t.Errorf("a bound in the wrong scopes")
+	}
+}
+
+func TestNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("z", &Integer{Value: 1})
+
+	env := NewEnclosedEnvironment(outer)
+	if names := env.Names(); len(names) != 0 {
+		t.Errorf("empty scope has names. got=%v", names)
+	}
+
+	env.Set("b", &Integer{Value: 2})
+	env.SetConst("a", &Integer{Value: 3})
+	env.Set("b", &Integer{Value: 4})
+
+	names := env.Names()
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("names wrong. expected=[a b], got=%v", names)
+	}
+}
@@ -1,20 +1,41 @@
 package object
 
 import (
+	"bytes"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sugiru/ast"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ = "INTEGER"
+	FLOAT_OBJ   = "FLOAT"
 	BOOLEAN_OBJ = "BOOLEAN"
 	NULL_OBJ    = "NULL"
+	STRING_OBJ  = "STRING"
+	ERROR_OBJ   = "ERROR"
+	BUILTIN_OBJ = "BUILTIN"
+	ARRAY_OBJ   = "ARRAY"
+	HASH_OBJ    = "HASH"
+
+	RETURN_VALUE_OBJ    = "RETURN_VALUE"
+	BREAK_SIGNAL_OBJ    = "BREAK_SIGNAL"
+	CONTINUE_SIGNAL_OBJ = "CONTINUE_SIGNAL"
+	FUNCTION_OBJ        = "FUNCTION"
 )
 
 type Object interface {
 	Type() ObjectType
 	Inspect() string
+
+	// Equal compares by value, objects of different types are never equal.
+	// What has no value to compare, such as a function, is only equal to itself.
+	Equal(other Object) bool
 }
 
 type Integer struct {
@@ -23,6 +44,21 @@ type Integer struct {
 
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Equal(other Object) bool {
+	o, ok := other.(*Integer)
+	return ok && i.Value == o.Value
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Inspect() string  { return strconv.FormatFloat(f.Value, 'g', -1, 64) }
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Equal(other Object) bool {
+	o, ok := other.(*Float)
+	return ok && f.Value == o.Value
+}
 
 type Boolean struct {
 	Value bool
@@ -30,8 +66,228 @@ type Boolean struct {
 
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Equal(other Object) bool {
+	o, ok := other.(*Boolean)
+	return ok && b.Value == o.Value
+}
 
 type Null struct{}
 
 func (n *Null) Inspect() string  { return "null" }
 func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Equal(other Object) bool {
+	_, ok := other.(*Null)
+	return ok
+}
+
+type String struct {
+	Value string
+}
+
+// Inspect quotes the string so it reads like the literal it came from,
+// Value is the raw contents
+func (s *String) Inspect() string  { return strconv.Quote(s.Value) }
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Equal(other Object) bool {
+	o, ok := other.(*String)
+	return ok && s.Value == o.Value
+}
+
+// Error is a runtime error, it is propagated up until it reaches the top level
+type Error struct {
+	Message   string
+	Assertion bool // Raised by a failing `assert`, rather than by a mistake in the program
+}
+
+func (e *Error) Inspect() string         { return "ERROR: " + e.Message }
+func (e *Error) Type() ObjectType        { return ERROR_OBJ }
+func (e *Error) Equal(other Object) bool { return other == Object(e) }
+
+// ReturnValue wraps the value of a return statement while it is
+// propagated up to the function being returned from
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Equal(other Object) bool {
+	o, ok := other.(*ReturnValue)
+	return ok && rv.Value.Equal(o.Value)
+}
+
+// BreakSignal and ContinueSignal are propagated up from a break or
+// continue statement the same way as a ReturnValue, until a loop catches them
+type BreakSignal struct{}
+
+func (bs *BreakSignal) Inspect() string  { return "break" }
+func (bs *BreakSignal) Type() ObjectType { return BREAK_SIGNAL_OBJ }
+func (bs *BreakSignal) Equal(other Object) bool {
+	_, ok := other.(*BreakSignal)
+	return ok
+}
+
+type ContinueSignal struct{}
+
+func (cs *ContinueSignal) Inspect() string  { return "continue" }
+func (cs *ContinueSignal) Type() ObjectType { return CONTINUE_SIGNAL_OBJ }
+func (cs *ContinueSignal) Equal(other Object) bool {
+	_, ok := other.(*ContinueSignal)
+	return ok
+}
+
+type Function struct {
+	Name       string // The name the function was bound to, empty if anonymous
+	Parameters []*ast.Identifier
+	Variadic   bool // The last parameter collects the remaining arguments into an array
+	Body       *ast.BlockStatement
+	Env        *Environment // The environment the function closes over
+}
+
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+
+	var params []string
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	if f.Variadic {
+		params[len(params)-1] = "..." + params[len(params)-1]
+	}
+
+	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+func (f *Function) Type() ObjectType        { return FUNCTION_OBJ }
+func (f *Function) Equal(other Object) bool { return other == Object(f) }
+
+// BuiltinFunction is the signature of functions implemented in Go
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+func (b *Builtin) Inspect() string         { return "builtin function" }
+func (b *Builtin) Type() ObjectType        { return BUILTIN_OBJ }
+func (b *Builtin) Equal(other Object) bool { return other == Object(b) }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	var elements []string
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+
+// Equal holds when both arrays have equal elements pairwise
+func (a *Array) Equal(other Object) bool {
+	o, ok := other.(*Array)
+	if !ok || len(a.Elements) != len(o.Elements) {
+		return false
+	}
+	for i, el := range a.Elements {
+		if !el.Equal(o.Elements[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// HashKey identifies a hash key by its type and value,
+// so equal values of different types never collide
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by objects which can be used as hash keys
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	} else {
+		value = 0
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// HashPair keeps the original key next to its value
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+// Inspect lists the pairs sorted by key, so the output is stable
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+	sort.Strings(pairs)
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+
+// Equal holds when both hashes have equal values under the same keys
+func (h *Hash) Equal(other Object) bool {
+	o, ok := other.(*Hash)
+	if !ok || len(h.Pairs) != len(o.Pairs) {
+		return false
+	}
+	for key, pair := range h.Pairs {
+		otherPair, ok := o.Pairs[key]
+		if !ok || !pair.Value.Equal(otherPair.Value) {
+			return false
+		}
+	}
+	return true
+}
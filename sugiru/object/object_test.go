@@ -0,0 +1,136 @@
+package object
+
+import "testing"
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestIntegerAndBooleanHashKeys(t *testing.T) {
+	if (&Integer{Value: 42}).HashKey() != (&Integer{Value: 42}).HashKey() {
+		t.Errorf("integers with same value have different hash keys")
+	}
+	if (&Integer{Value: 1}).HashKey() == (&Integer{Value: 2}).HashKey() {
+		t.Errorf("integers with different values have same hash keys")
+	}
+	if (&Boolean{Value: true}).HashKey() != (&Boolean{Value: true}).HashKey() {
+		t.Errorf("booleans with same value have different hash keys")
+	}
+	if (&Boolean{Value: true}).HashKey() == (&Boolean{Value: false}).HashKey() {
+		t.Errorf("booleans with different values have same hash keys")
+	}
+}
+
+func TestHashKeysOfDifferentTypes(t *testing.T) {
+	// The values behind these are equal, only the types set them apart
+	tests := []struct {
+		a, b Hashable
+	}{
+		{&Integer{Value: 1}, &Boolean{Value: true}},
+		{&Integer{Value: 0}, &Boolean{Value: false}},
+		{&Integer{Value: int64((&String{Value: "a"}).HashKey().Value)}, &String{Value: "a"}},
+	}
+
+	for _, tt := range tests {
+		if tt.a.HashKey() == tt.b.HashKey() {
+			t.Errorf("%s and %s have the same hash key", tt.a.(Object).Inspect(), tt.b.(Object).Inspect())
+		}
+		if tt.a.HashKey().Value != tt.b.HashKey().Value {
+			t.Errorf("%s and %s should share the hash key value", tt.a.(Object).Inspect(), tt.b.(Object).Inspect())
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{&String{Value: "two"}, `"two"`},
+		{&String{Value: "say \"hi\"\n"}, `"say \"hi\"\n"`},
+		{&Array{Elements: []Object{}}, "[]"},
+		{&Array{Elements: []Object{
+			&Integer{Value: 1},
+			&String{Value: "two"},
+			&Array{Elements: []Object{&Integer{Value: 3}, &Integer{Value: 4}}},
+		}}, `[1, "two", [3, 4]]`},
+		{&Array{Elements: []Object{
+			&Array{Elements: []Object{&Array{Elements: []Object{&String{Value: "deep"}}}}},
+			&Boolean{Value: true},
+			&Float{Value: 1.5},
+		}}, `[[["deep"]], true, 1.5]`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect. expected=%s, got=%s", tt.expected, got)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	array := func(elements ...Object) *Array { return &Array{Elements: elements} }
+	builtin := &Builtin{Fn: func(args ...Object) Object { return nil }}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Integer{Value: 2}, false},
+		{&Integer{Value: 1}, &Float{Value: 1}, false},
+		{&Float{Value: 1.5}, &Float{Value: 1.5}, true},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "a"}, &String{Value: "b"}, false},
+		{&String{Value: "1"}, &Integer{Value: 1}, false},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Boolean{Value: true}, &Boolean{Value: false}, false},
+		{&Boolean{Value: false}, &Null{}, false},
+		{&Null{}, &Null{}, true},
+		{array(), array(), true},
+		{array(&Integer{Value: 1}, &String{Value: "a"}), array(&Integer{Value: 1}, &String{Value: "a"}), true},
+		{array(&Integer{Value: 1}), array(&Integer{Value: 1}, &Integer{Value: 2}), false},
+		{array(&Integer{Value: 1}, &Integer{Value: 2}), array(&Integer{Value: 2}, &Integer{Value: 1}), false},
+		{array(array(&Integer{Value: 1})), array(array(&Integer{Value: 1})), true},
+		{array(array(&Integer{Value: 1})), array(array(&Integer{Value: 2})), false},
+		{array(), &Hash{Pairs: map[HashKey]HashPair{}}, false},
+		{builtin, builtin, true},
+		{builtin, &Builtin{Fn: builtin.Fn}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.expected {
+			t.Errorf("%s.Equal(%s) wrong. expected=%t, got=%t", tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+		// Equality goes both ways
+		if got := tt.b.Equal(tt.a); got != tt.expected {
+			t.Errorf("%s.Equal(%s) wrong. expected=%t, got=%t", tt.b.Inspect(), tt.a.Inspect(), tt.expected, got)
+		}
+	}
+
+	key := (&String{Value: "k"}).HashKey()
+	h1 := &Hash{Pairs: map[HashKey]HashPair{key: {Key: &String{Value: "k"}, Value: array(&Integer{Value: 1})}}}
+	h2 := &Hash{Pairs: map[HashKey]HashPair{key: {Key: &String{Value: "k"}, Value: array(&Integer{Value: 1})}}}
+	h3 := &Hash{Pairs: map[HashKey]HashPair{key: {Key: &String{Value: "k"}, Value: array()}}}
+	if !h1.Equal(h2) {
+		t.Errorf("hashes with the same pairs are not equal")
+	}
+	if h1.Equal(h3) {
+		t.Errorf("hashes with different values are equal")
+	}
+}
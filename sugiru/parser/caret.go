@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"strings"
+)
+
+// RenderCaret returns the source line at the given position with
+// a `^` on the line below, pointing at the column. Tabs before the
+// column are kept so the caret lines up with the source as displayed.
+// An empty string is returned if the line doesn't exist.
+func RenderCaret(source string, line int, column int) string {
+	lines := strings.Split(source, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	text := strings.TrimRight(lines[line-1], "\r")
+
+	// Columns count bytes, clamp them to the end of the line
+	end := column - 1
+	if end < 0 {
+		end = 0
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+
+	var pad strings.Builder
+	for _, r := range text[:end] {
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	return text + "\n" + pad.String() + "^"
+}
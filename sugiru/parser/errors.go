@@ -0,0 +1,20 @@
+package parser
+
+import (
+	"fmt"
+	"sugiru/token"
+)
+
+// ParseError is a single error reported by the parser, along with
+// the token that caused it so callers can point at the source.
+type ParseError struct {
+	Message string
+	Token   token.Token // The offending token
+	Line    int         // 1-based line of the offending token
+	Column  int         // 1-based column of the offending token
+}
+
+// Error formats the error as "line:column: message"
+func (pe ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", pe.Line, pe.Column, pe.Message)
+}
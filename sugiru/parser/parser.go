@@ -14,7 +14,12 @@ type Parser struct {
 	curToken  token.Token // Pointer to the current token
 	peekToken token.Token // Pointer to the next token
 
-	errors []string
+	errors    []ParseError
+	recovered int // How many of the errors were skipped past
+
+	// How many parens, brackets or braces enclose curToken,
+	// line breaks only end statements outside of them
+	nesting int
 
 	prefixParserFns map[token.TokenType]prefixParserFn
 	infixParseFns   map[token.TokenType]infixParserFn
@@ -29,31 +34,53 @@ func (p *Parser) init() {
 	p.prefixParserFns = make(map[token.TokenType]prefixParserFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.PLUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionExpression)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.INCR, p.parsePrefixUpdateExpression)
+	p.registerPrefix(token.DECR, p.parsePrefixUpdateExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParserFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.POW, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
+	p.registerInfix(token.QUESTION, p.parseTernaryExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.INCR, p.parsePostfixUpdateExpression)
+	p.registerInfix(token.DECR, p.parsePostfixUpdateExpression)
+	p.registerInfix(token.PLUS_EQ, p.parseCompoundAssignExpression)
+	p.registerInfix(token.MINUS_EQ, p.parseCompoundAssignExpression)
+	p.registerInfix(token.ASTERISK_EQ, p.parseCompoundAssignExpression)
+	p.registerInfix(token.SLASH_EQ, p.parseCompoundAssignExpression)
 
 }
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []ParseError{},
 	}
 
 	// Set up current and peek token
@@ -62,23 +89,55 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// Errors returns the message of every error, in the order they were reported
 func (p *Parser) Errors() []string {
+	msgs := make([]string, 0, len(p.errors))
+	for _, err := range p.errors {
+		msgs = append(msgs, err.Message)
+	}
+	return msgs
+}
+
+// ParseErrors returns every error along with the token that caused it
+func (p *Parser) ParseErrors() []ParseError {
 	return p.errors
 }
 
+// addError records an error caused by the given token
+func (p *Parser) addError(tok token.Token, msg string) {
+	p.errors = append(p.errors, ParseError{
+		Message: msg,
+		Token:   tok,
+		Line:    tok.Line,
+		Column:  tok.Column,
+	})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	if t == token.ILLEGAL {
+		// The lexer gives the reason for the strings it gave up on
+		msg = p.curToken.Reason
+		if msg == "" {
+			msg = fmt.Sprintf("illegal character %q", p.curToken.Literal)
+		}
+	}
+	p.addError(p.curToken, msg)
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken      // Retrieves the next current from the peek
 	p.peekToken = p.l.NextToken() // Retrieves the next token from the lexer
+
+	// Comments kept by the lexer don't take part in parsing
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -88,8 +147,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	// Keep iterating until we reach an EOF token
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt := p.parseStatementOrSkip(); stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 
@@ -100,25 +158,141 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// ParseExpression parses the input as a single expression, which may be
+// followed by a `;` but nothing else. The errors are the same messages as
+// returned by Errors, the expression may be incomplete when there are any.
+func ParseExpression(input string) (ast.Expression, []string) {
+	p := New(lexer.New(input))
+
+	exp := p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	// Report leftovers unless the expression itself already failed
+	if len(p.errors) == 0 && !p.peekTokenIs(token.EOF) {
+		p.addError(p.peekToken, fmt.Sprintf("expected a single expression, got %s after it", p.peekToken.Type))
+	}
+
+	return exp, p.Errors()
+}
+
+// parseStatementOrSkip parses the statement, when it fails to parse the rest of
+// it is skipped and nil is returned, so one mistake doesn't cascade in more errors
+func (p *Parser) parseStatementOrSkip() ast.Statement {
+	errors := len(p.errors)
+	stmt := p.parseStatement()
+
+	// The errors of nested blocks were already recovered from
+	if p.recovered > errors {
+		errors = p.recovered
+	}
+	if len(p.errors) == errors {
+		return stmt
+	}
+
+	p.skipStatement()
+	p.recovered = len(p.errors)
+	return nil
+}
+
+// skipStatement moves onto the `;` ending the current statement, or the
+// token before the next statement keyword or the `}` closing the block.
+// Braces opened along the way are skipped whole.
+func (p *Parser) skipStatement() {
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			depth--
+		case token.SEMICOLON:
+			if depth <= 0 {
+				return
+			}
+		}
+
+		if depth <= 0 {
+			switch p.peekToken.Type {
+			case token.LET, token.CONST, token.RETURN, token.FOR,
+				token.BREAK, token.CONTINUE, token.RBRACE, token.EOF:
+				return
+			}
+		}
+
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	// Parse according to the current token
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
+		if p.peekTokenIs(token.LBRACKET) {
+			return p.parseDestructureStatement()
+		}
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.FUNCTION:
+		// A name after `fn` makes it a declaration rather than a function literal
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionDeclaration()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 // parseLetStatement parses the let statement, the expected form
-// being: 'let' 'IDENT' '=' 'VALUE' ';'
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	// Note: The current IS ALWAYS token.LET
+// being: 'let' 'IDENT' '=' 'VALUE' { ',' 'IDENT' '=' 'VALUE' } ';'
+// ( or 'const' in place of 'let' ). More than one binding makes a MultiLetStatement.
+func (p *Parser) parseLetStatement() ast.Statement {
+	// Note: The current IS ALWAYS token.LET or token.CONST
+	tok := p.curToken
+
+	first := p.parseLetBinding(tok)
+	if first == nil {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.COMMA) {
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return first
+	}
+
+	stmt := &ast.MultiLetStatement{Token: tok, Lets: []*ast.LetStatement{first}}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+
+		let := p.parseLetBinding(tok)
+		if let == nil {
+			return nil
+		}
+		stmt.Lets = append(stmt.Lets, let)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
 
+	return stmt
+}
+
+// parseLetBinding parses a single 'IDENT' '=' 'VALUE' following the current
+// token, which is the `let` or a `,` between bindings
+func (p *Parser) parseLetBinding(tok token.Token) *ast.LetStatement {
 	// Constructs a new AST node (*ast.LetStatement node)
-	stmt := &ast.LetStatement{Token: p.curToken}
+	stmt := &ast.LetStatement{Token: tok, Constant: tok.Type == token.CONST}
 
 	// We expect to see an identifier after the 'let' keyword
 	// example: let x
@@ -139,6 +313,154 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
 
+	// A function bound by let takes the name of the binding
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
+	return stmt
+}
+
+// parseDestructureStatement parses the let statement binding the elements of
+// an array, the expected form being: 'let' '[' 'IDENT' { ',' 'IDENT' } ']' '=' 'VALUE' ';'
+// ( or 'const' in place of 'let' ), a trailing comma is allowed within the brackets
+func (p *Parser) parseDestructureStatement() *ast.DestructureStatement {
+	// Note: The current IS ALWAYS token.LET or token.CONST, followed by `[`
+	stmt := &ast.DestructureStatement{Token: p.curToken, Constant: p.curTokenIs(token.CONST)}
+	p.nextToken()
+
+	// There is at least one name
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+
+		if p.peekTokenIs(token.RBRACKET) {
+			break
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	// Parse the expression
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseFunctionDeclaration parses a named function, the expected form being:
+// 'fn' 'IDENT' '(' [PARAMS] ')' BLOCK, which is parsed into the let statement
+// 'let' 'IDENT' '=' 'fn' '(' [PARAMS] ')' BLOCK
+func (p *Parser) parseFunctionDeclaration() ast.Statement {
+	// Note: The current IS ALWAYS token.FUNCTION, followed by the name
+	function := &ast.FunctionLiteral{Token: p.curToken}
+
+	// The let doesn't appear in the source, it takes the position of the `fn`
+	stmt := &ast.LetStatement{Token: token.Token{
+		Type:    token.LET,
+		Literal: "let",
+		Line:    p.curToken.Line,
+		Column:  p.curToken.Column,
+	}}
+
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	function.Name = stmt.Name.Value
+
+	if !p.parseFunctionRest(function) {
+		return nil
+	}
+	stmt.Value = function
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseForStatement parses a C style loop, the expected form being:
+// 'for' '(' [STATEMENT] ';' [EXPRESSION] ';' [STATEMENT] ')' BLOCK
+func (p *Parser) parseForStatement() ast.Statement {
+	// Note: The current IS ALWAYS token.FOR
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+
+	// The header is enclosed within the parens, the body resets it
+	p.nesting++
+	defer func() { p.nesting-- }()
+
+	// The init statement consumes its own semicolon when it has one
+	if !p.curTokenIs(token.SEMICOLON) {
+		stmt.Init = p.parseStatement()
+		if isNilStatement(stmt.Init) {
+			return nil
+		}
+		if !p.curTokenIs(token.SEMICOLON) && !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.Condition = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		stmt.Post = p.parseStatement()
+		if isNilStatement(stmt.Post) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLoopControlStatement parses 'break' or 'continue' with an optional ';'
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	var stmt ast.Statement
+	if p.curTokenIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.curToken}
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -146,6 +468,28 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// isNilStatement returns whether parsing the statement failed, the
+// statement parsers return typed nil pointers which don't compare to nil
+func isNilStatement(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case nil:
+		return true
+	case *ast.LetStatement:
+		return stmt == nil
+	case *ast.MultiLetStatement:
+		return stmt == nil
+	case *ast.DestructureStatement:
+		return stmt == nil
+	case *ast.ReturnStatement:
+		return stmt == nil
+	case *ast.ExpressionStatement:
+		return stmt == nil
+	case *ast.ForStatement:
+		return stmt == nil
+	}
+	return false
+}
+
 // peekTokenIs returns whether the peek token is of specified type
 func (p *Parser) peekTokenIs(t token.TokenType) bool {
 	return p.peekToken.Type == t
@@ -171,6 +515,14 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
+	// A bare `return` has no value, the function returns null
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return stmt
+	}
+
 	// Advance the token
 	p.nextToken()
 
@@ -213,24 +565,45 @@ type (
 const (
 	_ int = iota // Used to give the following constants incrementing numbers as values ( _ takes 0 )
 	LOWEST
+	ASSIGN      // x = y
+	TERNARY     // a ? b : c
+	OR          // a || b
+	AND         // a && b
+	PIPE        // x |> f
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
+	POWER       // **
 	CALL        // fn()
+	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:      ASSIGN,
+	token.PLUS_EQ:     ASSIGN,
+	token.MINUS_EQ:    ASSIGN,
+	token.ASTERISK_EQ: ASSIGN,
+	token.SLASH_EQ:    ASSIGN,
+	token.QUESTION:    TERNARY,
+	token.OR:          OR,
+	token.AND:         AND,
+	token.PIPE:        PIPE,
+	token.EQ:          EQUALS,
+	token.NOT_EQ:      EQUALS,
+	token.LT:          LESSGREATER,
+	token.GT:          LESSGREATER,
+	token.PLUS:        SUM,
+	token.MINUS:       SUM,
+	token.SLASH:       PRODUCT,
+	token.PERCENT:     PRODUCT,
+	token.ASTERISK:    PRODUCT,
+	token.POW:         POWER,
+	token.LPAREN:      CALL,
+	token.LBRACKET:    INDEX,
+	token.INCR:        INDEX,
+	token.DECR:        INDEX,
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -249,6 +622,10 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	// If end of statement isn't reached and the current
 	// precedence is lower than the next, we evaluate the infix
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+		if p.lineBreakEndsExpression() {
+			return leftExp
+		}
+
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -261,6 +638,21 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
+// lineBreakEndsExpression reports whether the expression ends at a line break
+// before the peek token, which happens when the peek token could just as well
+// start the next statement, e.g. `x` followed by `(1)` on the next line
+func (p *Parser) lineBreakEndsExpression() bool {
+	if p.nesting > 0 || p.peekToken.Line == p.curToken.Line {
+		return false
+	}
+
+	switch p.peekToken.Type {
+	case token.LPAREN, token.LBRACKET, token.MINUS, token.INCR, token.DECR:
+		return true
+	}
+	return false
+}
+
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParserFn) {
 	p.prefixParserFns[tokenType] = fn
 }
@@ -280,7 +672,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	// Error converting
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 
@@ -289,6 +681,26 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return il
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	fl := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+
+	// Error converting
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(p.curToken, msg)
+		return nil
+	}
+
+	fl.Value = value
+
+	return fl
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -297,6 +709,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 	p.nextToken()
 
+	// A following `**` binds to the operand first, `-5 ** 2` is -25
 	expression.Right = p.parseExpression(PREFIX)
 	return expression
 }
@@ -324,6 +737,12 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 
 	precedence := p.curPrecedence()
 
+	// Parsing the right side just below its own precedence makes `**`
+	// right associative, `2 ** 3 ** 2` groups as `2 ** (3 ** 2)`
+	if p.curTokenIs(token.POW) {
+		precedence--
+	}
+
 	// Move to the right hand side expression
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
@@ -331,11 +750,164 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTernaryExpression(condition ast.Expression) ast.Expression {
+	expression := &ast.TernaryExpression{Token: p.curToken, Condition: condition}
+
+	// Move onto the then branch
+	p.nextToken()
+	expression.Then = p.parseExpression(LOWEST)
+
+	// The branches are separated by `:`
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	// Move onto the else branch, parsing it at the lowest precedence
+	// makes `a ? b : c ? d : e` group as `a ? b : (c ? d : e)`
+	p.nextToken()
+	expression.Else = p.parseExpression(LOWEST)
+
+	return expression
+}
+
+// parseAssignExpression parses the assignment of an existing
+// binding, the expected form being: 'IDENT' '=' 'VALUE'
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	switch target := target.(type) {
+	case *ast.Identifier:
+		// Parsing the value just below ASSIGN makes it right associative,
+		// `a = b = 5` groups as `a = (b = 5)`
+		p.nextToken()
+		return &ast.AssignExpression{Token: tok, Name: target, Value: p.parseExpression(ASSIGN - 1)}
+	case *ast.IndexExpression:
+		p.nextToken()
+		return &ast.IndexAssignExpression{Token: tok, Target: target, Value: p.parseExpression(ASSIGN - 1)}
+	}
+
+	// A nil target failed to parse and has been reported already
+	if target == nil {
+		return nil
+	}
+	p.addError(tok, fmt.Sprintf("cannot assign to %s", describeTarget(target)))
+	return nil
+}
+
+// describeTarget names an expression that can't be assigned to. The target may
+// be only partly parsed after an error, so it isn't printed with String, which
+// would trip over the missing pieces
+func describeTarget(target ast.Expression) string {
+	switch target := target.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean, *ast.NullLiteral:
+		return target.TokenLiteral()
+	case *ast.StringLiteral:
+		return strconv.Quote(target.Value)
+	case *ast.CallExpression:
+		return "a call"
+	case *ast.FunctionLiteral:
+		return "a function"
+	case *ast.ArrayLiteral:
+		return "an array"
+	case *ast.HashLiteral:
+		return "a hash"
+	}
+	return "an expression"
+}
+
+// parsePrefixUpdateExpression parses '++' 'IDENT' or '--' 'IDENT'
+func (p *Parser) parsePrefixUpdateExpression() ast.Expression {
+	expression := &ast.UpdateExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Prefix:   true,
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expression.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return expression
+}
+
+// parsePostfixUpdateExpression parses 'IDENT' '++' or 'IDENT' '--'
+func (p *Parser) parsePostfixUpdateExpression(target ast.Expression) ast.Expression {
+	name, ok := target.(*ast.Identifier)
+	if !ok {
+		if target != nil {
+			p.addError(p.curToken, fmt.Sprintf("cannot apply %s to %s", p.curToken.Literal, describeTarget(target)))
+		}
+		return nil
+	}
+
+	return &ast.UpdateExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Name:     name,
+	}
+}
+
+// compoundOperators maps each compound assignment onto its operator
+var compoundOperators = map[token.TokenType]token.TokenType{
+	token.PLUS_EQ:     token.PLUS,
+	token.MINUS_EQ:    token.MINUS,
+	token.ASTERISK_EQ: token.ASTERISK,
+	token.SLASH_EQ:    token.SLASH,
+}
+
+// parseCompoundAssignExpression desugars `x op= y` into `x = x op y`, so
+// the evaluator only sees a plain assignment. An index target keeps the
+// operator instead, as desugaring would evaluate its container and index twice
+func (p *Parser) parseCompoundAssignExpression(target ast.Expression) ast.Expression {
+	compound := p.curToken
+	operator := string(compoundOperators[compound.Type])
+
+	switch target := target.(type) {
+	case *ast.Identifier:
+		// The operator token sits where the compound token was
+		infix := compound
+		infix.Type = compoundOperators[compound.Type]
+		infix.Literal = operator
+
+		p.nextToken()
+		value := &ast.InfixExpression{
+			Token:    infix,
+			Operator: operator,
+			Left:     target,
+			Right:    p.parseExpression(ASSIGN - 1),
+		}
+		return &ast.AssignExpression{Token: compound, Name: target, Value: value}
+	case *ast.IndexExpression:
+		p.nextToken()
+		return &ast.IndexAssignExpression{
+			Token:    compound,
+			Target:   target,
+			Operator: operator,
+			Value:    p.parseExpression(ASSIGN - 1),
+		}
+	}
+
+	// A nil target failed to parse and has been reported already
+	if target == nil {
+		return nil
+	}
+	p.addError(compound, fmt.Sprintf("cannot assign to %s", describeTarget(target)))
+	return nil
+}
+
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.nesting++
+	defer func() { p.nesting-- }()
+
 	// Consume the LPAREN and move onto the expression
 	p.nextToken()
 
@@ -360,7 +932,9 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 	// Move onto the condition and parse it
 	p.nextToken()
+	p.nesting++
 	expression.Condition = p.parseExpression(LOWEST)
+	p.nesting--
 
 	// We expect right paren to enclose the condition
 	if !p.expectPeek(token.RPAREN) {
@@ -382,6 +956,21 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		// Move to the Else token
 		p.nextToken()
 
+		// An `else if` chains into another if expression, which
+		// becomes the only statement of the else branch
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			block := &ast.BlockStatement{Token: p.curToken}
+			stmt := &ast.ExpressionStatement{Token: p.curToken}
+			stmt.Expression = p.parseIfExpression()
+			if stmt.Expression == nil {
+				return nil
+			}
+			block.Statements = []ast.Statement{stmt}
+			expression.Else = block
+			return expression
+		}
+
 		// We expect else body to start with {
 		if !p.expectPeek(token.LBRACE) {
 			return nil
@@ -400,11 +989,15 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	// The block holds statements, even when it's within parens
+	defer func(nesting int) { p.nesting = nesting }(p.nesting)
+	p.nesting = 0
+
 	p.nextToken()
 
 	// While we haven't reached the end of the block, and we're not at the EOF
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		stmt := p.parseStatementOrSkip()
 
 		// Nest the statement to the block
 		if stmt != nil {
@@ -421,19 +1014,27 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 func (p *Parser) parseFunctionExpression() ast.Expression {
 	expression := &ast.FunctionLiteral{Token: p.curToken}
+	if !p.parseFunctionRest(expression) {
+		return nil
+	}
+	return expression
+}
 
+// parseFunctionRest parses the parameters and body of the function,
+// curToken is the token before the `(` and is left on the closing `}`
+func (p *Parser) parseFunctionRest(expression *ast.FunctionLiteral) bool {
 	// Move to the expected `(`
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return false
 	}
 
 	// Start parsing parameters ( curToken is `(` )
-	expression.Parameters = p.parseFunctionParameters()
+	expression.Parameters, expression.Variadic = p.parseFunctionParameters()
 	// End, curToken at `)`
 
 	// We expect the body to begin
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return false
 	}
 
 	// curToken is '{'
@@ -441,10 +1042,10 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 	// Parse body
 	expression.Body = p.parseBlockStatement()
 
-	return expression
+	return true
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, bool) {
 	var identifiers []*ast.Identifier
 
 	// Note curToken is at `(`
@@ -452,32 +1053,61 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	// In the case of void param, next token is `)`
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, false
 	}
 
 	// Move to the first param token
 	p.nextToken()
 
 	// Manually parse the first identifier
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident, variadic := p.parseParameter()
+	if ident == nil {
+		return nil, false
+	}
 	identifiers = append(identifiers, ident)
 
 	// While there is a comma, we parse the next ident
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken() // Comma
+
+		// A trailing comma is allowed before the `)`
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
+
+		// Nothing can follow the parameter collecting the rest
+		if variadic {
+			p.addError(p.peekToken, "variadic parameter must be the last parameter")
+			return nil, false
+		}
+
 		p.nextToken() // Next identifier
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		ident, variadic = p.parseParameter()
+		if ident == nil {
+			return nil, false
+		}
 		identifiers = append(identifiers, ident)
 	}
 
 	// Get the enclosing right paren,
 	// move onto it if it exists
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, false
 	}
 
 	// By the time we reach here, cur token is `)`
-	return identifiers
+	return identifiers, variadic
+}
+
+// parseParameter parses the parameter at the current token, a
+// parameter written as `...name` is variadic
+func (p *Parser) parseParameter() (*ast.Identifier, bool) {
+	variadic := p.curTokenIs(token.ELLIPSIS)
+	if variadic && !p.expectPeek(token.IDENT) {
+		return nil, false
+	}
+
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}, variadic
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -485,32 +1115,165 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 		Token:    p.curToken,
 		Function: function,
 	}
-	expression.Arguments = p.parseCallArguments()
+	expression.Arguments = p.parseExpressionListWith(token.RPAREN, p.parseCallArgument)
 	return expression
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	var args []ast.Expression
+// parseCallArgument parses an argument of a call, which may be spread: `...xs`
+func (p *Parser) parseCallArgument() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	return spread
+}
+
+// parseExpressionList parses comma separated expressions until the end token,
+// curToken is the opening token and is left on the end token
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	return p.parseExpressionListWith(end, func() ast.Expression { return p.parseExpression(LOWEST) })
+}
+
+// parseExpressionListWith is parseExpressionList, with each element
+// parsed by the given function starting on its first token
+func (p *Parser) parseExpressionListWith(end token.TokenType, parseElement func() ast.Expression) []ast.Expression {
+	var list []ast.Expression
 
-	// For void args
-	if p.peekTokenIs(token.RPAREN) {
+	p.nesting++
+	defer func() { p.nesting-- }()
+
+	// For empty lists
+	if p.peekTokenIs(end) {
 		p.nextToken() // Move onto it
-		return args
+		return list
 	}
 
-	// Move onto first arg
+	// Move onto first element
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, parseElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken() // Comma
-		p.nextToken() // Arg
-		args = append(args, p.parseExpression(LOWEST))
+
+		// A trailing comma is allowed before the end token
+		if p.peekTokenIs(end) {
+			break
+		}
+
+		p.nextToken() // Element
+		list = append(list, parseElement())
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	return array
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+
+	p.nesting++
+	defer func() { p.nesting-- }()
+
+	// Note curToken is at `{`
+	for !p.peekTokenIs(token.RBRACE) {
+		// Move onto the key
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		// Move onto the value
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashLiteralPair{Key: key, Value: value})
+
+		// Pairs are separated by commas
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	// Move onto the closing `}`
+	p.nextToken()
+
+	return hash
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	expression := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nesting++
+	defer func() { p.nesting-- }()
+
+	// Move onto the index
+	p.nextToken()
+	expression.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return expression
+}
+
+// PLACEHOLDER marks where the piped value goes in the call on the right of `|>`
+const PLACEHOLDER = "_"
+
+// parsePipeExpression desugars `x |> f(a, b)` into the call `f(x, a, b)`.
+// When an argument of the call is the placeholder `_`, as in `x |> f(a, _)`,
+// the piped value takes its place instead, giving `f(a, x)`. Anything else
+// on the right is called with the piped value as its only argument.
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	pipe := p.curToken
+
+	// Move to the right hand side expression
+	p.nextToken()
+	right := p.parseExpression(PIPE)
+	if right == nil {
 		return nil
 	}
 
-	return args
+	call, ok := right.(*ast.CallExpression)
+	if !ok {
+		return &ast.CallExpression{
+			Token:     pipe,
+			Function:  right,
+			Arguments: []ast.Expression{left},
+		}
+	}
+
+	// Look for the placeholder, there may be at most one
+	placeholder := -1
+	for i, arg := range call.Arguments {
+		if ident, ok := arg.(*ast.Identifier); ok && ident.Value == PLACEHOLDER {
+			if placeholder != -1 {
+				p.addError(ident.Token, "only one placeholder `_` is allowed in a pipe")
+				return nil
+			}
+			placeholder = i
+		}
+	}
+
+	if placeholder == -1 {
+		call.Arguments = append([]ast.Expression{left}, call.Arguments...)
+	} else {
+		call.Arguments[placeholder] = left
+	}
+
+	return call
 }
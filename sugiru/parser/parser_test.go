@@ -2,8 +2,10 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"sugiru/ast"
 	"sugiru/lexer"
+	"sugiru/token"
 	"testing"
 )
 
@@ -75,6 +77,130 @@ func testLetStatements(t *testing.T, s ast.Statement, name string) bool {
 	return true
 }
 
+func TestDestructureStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		names    []string
+		constant bool
+		expected string
+	}{
+		{"let [a, b] = [1, 2];", []string{"a", "b"}, false, "let [a, b] = [1, 2];"},
+		{"let [x] = f()", []string{"x"}, false, "let [x] = f();"},
+		{"const [a, _, c,] = xs", []string{"a", "_", "c"}, true, "const [a, _, c] = xs;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.DestructureStatement)
+		if !ok {
+			t.Fatalf("stmt is not *ast.DestructureStatement. got=%T", program.Statements[0])
+		}
+		if len(stmt.Names) != len(tt.names) {
+			t.Fatalf("wrong number of names. want=%d, got=%d", len(tt.names), len(stmt.Names))
+		}
+		for i, name := range tt.names {
+			testIdentifier(t, stmt.Names[i], name)
+		}
+		if stmt.Constant != tt.constant {
+			t.Errorf("stmt.Constant wrong. want=%t, got=%t", tt.constant, stmt.Constant)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let [] = xs", "expected next token to be IDENT, got ] instead"},
+		{"let [a, 1] = xs", "expected next token to be IDENT, got INT instead"},
+		{"let [a b] = xs", "expected next token to be ], got IDENT instead"},
+		{"let [a] xs", "expected next token to be =, got IDENT instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong parser errors. want=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMultiLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		names    []string
+		constant bool
+		expected string
+	}{
+		{"let a = 1, b = 2, c = 3;", []string{"a", "b", "c"}, false, "let a = 1, b = 2, c = 3;"},
+		{"let a = 1, b = a + 1", []string{"a", "b"}, false, "let a = 1, b = (a + 1);"},
+		{"const x = f(1, 2), y = [x, 3]", []string{"x", "y"}, true, "const x = f(1, 2), y = [x, 3];"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.MultiLetStatement)
+		if !ok {
+			t.Fatalf("stmt is not *ast.MultiLetStatement. got=%T", program.Statements[0])
+		}
+		if len(stmt.Lets) != len(tt.names) {
+			t.Fatalf("wrong number of bindings. want=%d, got=%d", len(tt.names), len(stmt.Lets))
+		}
+		for i, name := range tt.names {
+			testIdentifier(t, stmt.Lets[i].Name, name)
+			if stmt.Lets[i].Token != stmt.Token {
+				t.Errorf("stmt.Lets[%d].Token wrong. want=%+v, got=%+v", i, stmt.Token, stmt.Lets[i].Token)
+			}
+			if stmt.Lets[i].Constant != tt.constant {
+				t.Errorf("stmt.Lets[%d].Constant wrong. want=%t, got=%t", i, tt.constant, stmt.Lets[i].Constant)
+			}
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+
+	// A single binding is still a plain let statement
+	program := New(lexer.New("let a = 1;")).ParseProgram()
+	if _, ok := program.Statements[0].(*ast.LetStatement); !ok {
+		t.Errorf("stmt is not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1, = 2", "expected next token to be IDENT, got = instead"},
+		{"let a = 1, b", "expected next token to be =, got EOF instead"},
+		{"let a = 1,", "expected next token to be IDENT, got EOF instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong parser errors. want=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	input := `
 	return 5;
@@ -102,6 +228,39 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestBareReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return;", "return;"},
+		{"return", "return;"},
+		{"fn() { return; }", "fn() { return; }"},
+		{"fn() { return }", "fn() { return; }"},
+		{"fn() { if (x) { return; } x }", "fn() { if (x) { return; }; x; }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	// The statement itself holds no value
+	program := New(lexer.New("return;")).ParseProgram()
+	stmt, ok := program.Statements[0].(*ast.ReturnStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ReturnStatement. got=%T", program.Statements[0])
+	}
+	if stmt.ReturnValue != nil {
+		t.Errorf("stmt.ReturnValue not nil. got=%s", stmt.ReturnValue.String())
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 	l := lexer.New(input)
@@ -163,6 +322,44 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "3.14;"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 3.14 {
+		t.Errorf("literal.Value not %f. got=%f", 3.14, literal.Value)
+	}
+	if literal.TokenLiteral() != "3.14" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "3.14",
+			literal.TokenLiteral())
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -171,6 +368,9 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15;", "-", 15},
+		{"+5;", "+", 5},
+		{"+x;", "+", "x"},
+		{"+true;", "+", true},
 		{"!foobar;", "!", "foobar"},
 		{"-foobar;", "-", "foobar"},
 		{"!true;", "!", true},
@@ -410,6 +610,74 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!-a",
 			"(!(-a))",
 		},
+		{
+			"+a * b",
+			"((+a) * b)",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"10 - 2 - 3",
+			"((10 - 2) - 3)",
+		},
+		{
+			"20 / 4 / 2",
+			"((20 / 4) / 2)",
+		},
+		{
+			"a % b % c",
+			"((a % b) % c)",
+		},
+		{
+			"a % b * c / d",
+			"(((a % b) * c) / d)",
+		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"-a % b",
+			"((-a) % b)",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a ** b * c",
+			"((a ** b) * c)",
+		},
+		{
+			"-a ** 2",
+			"(-(a ** 2))",
+		},
+		{
+			"-5 ** 2",
+			"(-(5 ** 2))",
+		},
+		{
+			"!a ** b",
+			"(!(a ** b))",
+		},
+		{
+			"2 ** -a ** 2",
+			"(2 ** (-(a ** 2)))",
+		},
+		{
+			"-a ** 2 * b",
+			"((-(a ** 2)) * b)",
+		},
+		{
+			"a ** b[0]",
+			"(a ** (b[0]))",
+		},
+		{
+			"a + +b",
+			"(a + (+b))",
+		},
 		{
 			"a + b + c",
 			"((a + b) + c)",
@@ -503,6 +771,42 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"-a[-1]",
+			"(-(a[(-1)]))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == 1 || !b",
+			"((a == 1) || (!b))",
+		},
+		{
+			"a < b && b < c",
+			"((a < b) && (b < c))",
+		},
+		{
+			"a || b |> f",
+			"(a || f(b))",
+		},
+		{
+			"a || b ? c : d",
+			"((a || b) ? c : d)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -518,6 +822,166 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+func TestParseExpression(t *testing.T) {
+	exp, errors := ParseExpression("1 + 2 * 3")
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors: %v", errors)
+	}
+
+	// 1 + (2 * 3)
+	infix, ok := exp.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.InfixExpression. got=%T", exp)
+	}
+	if !testLiteralExpression(t, infix.Left, 1) {
+		return
+	}
+	if infix.Operator != "+" {
+		t.Fatalf("exp.Operator is not '+'. got=%q", infix.Operator)
+	}
+	if !testInfixExpression(t, infix.Right, 2, "*", 3) {
+		return
+	}
+
+	valid := []struct {
+		input    string
+		expected string
+	}{
+		{"f(x);", "f(x)"},
+		{"  [1, 2][0]  ", "([1, 2][0])"},
+		{"a || b", "(a || b)"},
+	}
+	for _, tt := range valid {
+		exp, errors := ParseExpression(tt.input)
+		if len(errors) != 0 {
+			t.Errorf("%q: unexpected errors: %v", tt.input, errors)
+			continue
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, exp.String())
+		}
+	}
+
+	invalid := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2; 3", "expected a single expression, got INT after it"},
+		{"1 2", "expected a single expression, got INT after it"},
+		{"let x = 1", "no prefix parse function for LET found"},
+		{"", "no prefix parse function for EOF found"},
+		{"(1 + 2", "expected next token to be ), got EOF instead"},
+	}
+	for _, tt := range invalid {
+		_, errors := ParseExpression(tt.input)
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestIllegalTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"abc`, "1:1: unterminated string"},
+		{`let s = "abc`, "1:9: unterminated string"},
+		{`puts("a\q")`, `1:6: invalid escape sequence \q in string`},
+		{"1 + @", `1:5: illegal character "@"`},
+		// A backslash is only an escape inside a string
+		{`\`, `1:1: illegal character "\\"`},
+		{`x + \q`, `1:5: illegal character "\\"`},
+		{`"\"`, "1:1: unterminated string"},
+		{`"a\"`, "1:1: unterminated string"},
+		{`"\\" + "\z"`, `1:8: invalid escape sequence \z in string`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errs := p.ParseErrors()
+		if len(errs) == 0 || errs[0].Error() != tt.expected {
+			t.Errorf("%q: expected error %q. got=%v", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestSpreadArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"max(...nums)", "max(...nums)"},
+		{"f(1, ...xs, 2)", "f(1, ...xs, 2)"},
+		{"f(...a, ...b,)", "f(...a, ...b)"},
+		{"f(...[1, 2] + ys)", "f(...([1, 2] + ys))"},
+		{"f(...g(x))", "f(...g(x))"},
+		{"xs |> f(...ys)", "f(xs, ...ys)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New("f(...xs)")).ParseProgram()
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	spread, ok := call.Arguments[0].(*ast.SpreadExpression)
+	if !ok {
+		t.Fatalf("argument is not *ast.SpreadExpression. got=%T", call.Arguments[0])
+	}
+	testIdentifier(t, spread.Value, "xs")
+
+	// Spreading is only for the arguments of a call
+	for _, input := range []string{"[...xs]", "...xs", "let a = ...xs"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != "no prefix parse function for ... found" {
+			t.Errorf("%q: wrong parser errors. got=%v", input, errors)
+		}
+	}
+}
+
+func TestLeftAssociativeOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		values   [3]int64
+	}{
+		{"10 - 2 - 3", "-", [3]int64{10, 2, 3}},
+		{"20 / 4 / 2", "/", [3]int64{20, 4, 2}},
+		{"17 % 5 % 2", "%", [3]int64{17, 5, 2}},
+		{"1 && 2 && 3", "&&", [3]int64{1, 2, 3}},
+		{"1 || 2 || 3", "||", [3]int64{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		// `a op b op c` nests on the left: ((a op b) op c)
+		exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+		if !ok {
+			t.Fatalf("exp is not ast.InfixExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+		}
+		if exp.Operator != tt.operator {
+			t.Fatalf("exp.Operator is not '%s'. got=%q", tt.operator, exp.Operator)
+		}
+		if !testInfixExpression(t, exp.Left, tt.values[0], tt.operator, tt.values[1]) {
+			return
+		}
+		testIntegerLiteral(t, exp.Right, tt.values[2])
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	input := `if (x < y) { x; y; let b = 2; }`
 
@@ -660,36 +1124,166 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
-func TestFunctionParameterParsing(t *testing.T) {
-	tests := []struct {
-		input          string
-		expectedParams []string
-	}{
-		{input: "fn() {};", expectedParams: []string{}},
-		{input: "fn(x) {};", expectedParams: []string{"x"}},
-		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
 	}
-	for _, tt := range tests {
-		l := lexer.New(tt.input)
-		p := New(l)
-		program := p.ParseProgram()
-		checkParserErrors(t, p)
-		stmt := program.Statements[0].(*ast.ExpressionStatement)
-		function := stmt.Expression.(*ast.FunctionLiteral)
-		if len(function.Parameters) != len(tt.expectedParams) {
-			t.Errorf("length parameters wrong. want %d, got=%d\n",
-				len(tt.expectedParams), len(function.Parameters))
-		}
-		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
-		}
+	if function.Name != "myFunction" {
+		t.Errorf("function literal name wrong. want 'myFunction', got=%q", function.Name)
 	}
 }
 
-func TestCallExpressionParsing(t *testing.T) {
-	input := "add(1, 2 * 3, 4 + 5);"
-	l := lexer.New(input)
-	p := New(l)
+func TestFunctionDeclaration(t *testing.T) {
+	input := "fn add(x, y) { x + y }"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	// The declaration is parsed as a let binding the function
+	stmt := program.Statements[0]
+	if !testLetStatements(t, stmt, "add") {
+		return
+	}
+	let := stmt.(*ast.LetStatement)
+	if let.Token.Line != 1 || let.Token.Column != 1 {
+		t.Errorf("let is not positioned at the fn. got=%d:%d", let.Token.Line, let.Token.Column)
+	}
+
+	function, ok := let.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("let.Value is not ast.FunctionLiteral. got=%T", let.Value)
+	}
+	if function.Name != "add" {
+		t.Errorf("function literal name wrong. want 'add', got=%q", function.Name)
+	}
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(function.Parameters))
+	}
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn f() {}; f()", "let f = fn() { };f()"},
+		{"fn f() { 1 } fn g() { 2 }", "let f = fn() { 1; };let g = fn() { 2; };"},
+		// Without a name it's still a function literal
+		{"fn(x) { x }(1)", "fn(x) { x; }(1)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d\n",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestVariadicParameters(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		variadic       bool
+		expected       string
+	}{
+		{"fn(...xs) {}", []string{"xs"}, true, "fn(...xs) { }"},
+		{"fn(a, b, ...rest) { rest }", []string{"a", "b", "rest"}, true, "fn(a, b, ...rest) { rest; }"},
+		{"fn(a, ...rest,) {}", []string{"a", "rest"}, true, "fn(a, ...rest) { }"},
+		{"fn(a, b) {}", []string{"a", "b"}, false, "fn(a, b) { }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+		if function.Variadic != tt.variadic {
+			t.Errorf("function.Variadic wrong. want=%t, got=%t", tt.variadic, function.Variadic)
+		}
+		if function.String() != tt.expected {
+			t.Errorf("function.String() wrong. want=%q, got=%q", tt.expected, function.String())
+		}
+	}
+
+	// Declarations take the same parameters
+	program := New(lexer.New("fn sum(...xs) { xs }")).ParseProgram()
+	if fl := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral); !fl.Variadic {
+		t.Errorf("declared function is not variadic")
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...xs, y) {}", "variadic parameter must be the last parameter"},
+		{"fn(...xs, ...ys) {}", "variadic parameter must be the last parameter"},
+		{"fn(...) {}", "expected next token to be IDENT, got ) instead"},
+		{"fn(a, ...1) {}", "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong parser errors. want=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+	l := lexer.New(input)
+	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 	if len(program.Statements) != 1 {
@@ -716,3 +1310,941 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
+
+func TestTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1, 2,)", "add(1, 2)"},
+		{"add(1,)", "add(1)"},
+		{"[1, 2, 3,]", "[1, 2, 3]"},
+		{"[[1,], 2,]", "[[1], 2]"},
+		{"fn(x, y,) { x }", "fn(x, y) { x; }"},
+		{"fn(x,) { x }(1,)", "fn(x) { x; }(1)"},
+		{"add(\n\t1,\n\t2,\n)", "add(1, 2)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	// Only a single comma may trail, and never on its own
+	for _, input := range []string{"add(1,,)", "add(,)", "[,]"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestNewlineSeparatedStatements(t *testing.T) {
+	tests := []struct {
+		input      string
+		statements int
+		expected   string
+	}{
+		{"let x = 5\nlet y = 6\nx + y", 3, "let x = 5;let y = 6;(x + y)"},
+		{"let x = 5\n-1", 2, "let x = 5;(-1)"},
+		{"f\n(1)", 2, "f1"},
+		{"let a = b\n[1, 2]", 2, "let a = b;[1, 2]"},
+		{"x\n++y", 2, "x(++y)"},
+		{"fn() {\n  let x = f\n  (x)\n}", 1, "fn() { let x = f; x; }"},
+		// An operator at the end of the line continues the expression
+		{"let x = 1 +\n2", 1, "let x = (1 + 2);"},
+		{"let x = 5 -\n1", 1, "let x = (5 - 1);"},
+		{"f(1)\n|> g", 1, "g(f(1))"},
+		// Inside parens, brackets and braces line breaks don't matter
+		{"(1\n- 2)", 1, "(1 - 2)"},
+		{"f(x\n(1), 2)", 1, "f(x(1), 2)"},
+		{"[a\n[0], b]", 1, "[(a[0]), b]"},
+		{"a[b\n[0]]", 1, "(a[(b[0])])"},
+		{"{\"a\": x\n-1}", 1, `{"a": (x - 1)}`},
+		{"if (x\n- 1) { y }", 1, "if ((x - 1)) { y; }"},
+		// Blocks hold statements again, even within parens
+		{"f(fn() { a\n-1 })", 1, "f(fn() { a; (-1); })"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != tt.statements {
+			t.Errorf("%q: wrong number of statements. want=%d, got=%d",
+				tt.input, tt.statements, len(program.Statements))
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestCommentsAreIgnored(t *testing.T) {
+	input := `// adds two numbers
+let add = fn(a, b) { // the body
+	a + b // no semicolon
+	// before the brace
+};
+add(1, 2) // the call`
+
+	expected := "let add = fn(a, b) { (a + b); };add(1, 2)"
+
+	// Kept comments parse the same as skipped ones
+	for _, l := range []*lexer.Lexer{
+		lexer.New(input),
+		lexer.NewWithOptions(input, lexer.Options{Comments: true}),
+	} {
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != expected {
+			t.Errorf("expected=%q, got=%q", expected, actual)
+		}
+	}
+}
+
+func TestProgramStringRoundTrip(t *testing.T) {
+	input := `
+	let x = 5 + 5;
+	let add = fn(a, b) { let c = a + b; c * 2 };
+	let max = fn(a, b) { if (a > b) { return a; } else { b } };
+	substr("hello", 1, x);
+	return add(x, -max(1, 2));
+	`
+
+	expected := "let x = (5 + 5);" +
+		"let add = fn(a, b) { let c = (a + b); (c * 2); };" +
+		"let max = fn(a, b) { if ((a > b)) { return a; } else { b; }; };" +
+		`substr("hello", 1, x)` +
+		"return add(x, (-max(1, 2)));"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	actual := program.String()
+	if actual != expected {
+		t.Fatalf("program.String() wrong.\nexpected=%q\ngot=%q", expected, actual)
+	}
+
+	// Parsing the printed program should produce the exact same tree
+	l = lexer.New(actual)
+	p = New(l)
+	reparsed := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if reparsed.String() != actual {
+		t.Errorf("reparsed program differs.\nexpected=%q\ngot=%q", actual, reparsed.String())
+	}
+}
+
+func TestRenderCaret(t *testing.T) {
+	input := "let x = 5;\nlet = 10;"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.ParseErrors()) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+
+	err := p.ParseErrors()[0]
+	if err.Line != 2 || err.Column != 5 {
+		t.Fatalf("wrong error position. expected=2:5, got=%d:%d", err.Line, err.Column)
+	}
+
+	expected := "let = 10;\n    ^"
+	if rendered := RenderCaret(input, err.Line, err.Column); rendered != expected {
+		t.Errorf("wrong caret.\nexpected=%q\ngot=%q", expected, rendered)
+	}
+
+	tests := []struct {
+		source   string
+		line     int
+		column   int
+		expected string
+	}{
+		{"\tlet = 1;", 1, 6, "\tlet = 1;\n\t    ^"},
+		{"é = 1;", 1, 4, "é = 1;\n  ^"},
+		{"x +", 1, 4, "x +\n   ^"},
+		{"x", 3, 1, ""},
+	}
+
+	for _, tt := range tests {
+		if rendered := RenderCaret(tt.source, tt.line, tt.column); rendered != tt.expected {
+			t.Errorf("wrong caret.\nexpected=%q\ngot=%q", tt.expected, rendered)
+		}
+	}
+}
+
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { z }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	// The else branch holds the chained if expression
+	if exp.Else == nil || len(exp.Else.Statements) != 1 {
+		t.Fatalf("exp.Else does not contain 1 statement. got=%+v", exp.Else)
+	}
+	elseStmt, ok := exp.Else.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("exp.Else.Statements[0] is not ast.ExpressionStatement. got=%T",
+			exp.Else.Statements[0])
+	}
+	chained, ok := elseStmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("else branch is not ast.IfExpression. got=%T", elseStmt.Expression)
+	}
+	if !testInfixExpression(t, chained.Condition, "x", ">", "y") {
+		return
+	}
+
+	then := chained.Then.Statements[0].(*ast.ExpressionStatement)
+	if !testIdentifier(t, then.Expression, "y") {
+		return
+	}
+	last := chained.Else.Statements[0].(*ast.ExpressionStatement)
+	if !testIdentifier(t, last.Expression, "z") {
+		return
+	}
+
+	expected := "if ((x < y)) { x; } else { if ((x > y)) { y; } else { z; }; }"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong.\nexpected=%q\ngot=%q", expected, program.String())
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	input := "const PI = 3;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T",
+			program.Statements[0])
+	}
+	if !stmt.Constant {
+		t.Errorf("stmt.Constant is not true")
+	}
+	if stmt.Name.Value != "PI" {
+		t.Errorf("stmt.Name not 'PI'. got=%s", stmt.Name.Value)
+	}
+	testLiteralExpression(t, stmt.Value, 3)
+
+	if program.String() != "const PI = 3;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	input := "x = y + 1; x == y;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+	exp, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Name, "x") {
+		return
+	}
+	testInfixExpression(t, exp.Value, "y", "+", 1)
+
+	// A comparison is still an expression statement
+	if _, ok := program.Statements[1].(*ast.ExpressionStatement); !ok {
+		t.Fatalf("program.Statements[1] is not ast.ExpressionStatement. got=%T",
+			program.Statements[1])
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// The piped value becomes the first argument by default
+		{"x |> f", "f(x)"},
+		{"x |> f()", "f(x)"},
+		{"x |> f(1, 2)", "f(x, 1, 2)"},
+		// The placeholder marks where it goes instead
+		{"x |> f(_, 2)", "f(x, 2)"},
+		{"x |> f(1, _)", "f(1, x)"},
+		{"x |> f(1, _, 3)", "f(1, x, 3)"},
+		// Pipes chain left to right and bind looser than other operators
+		{"x |> f |> g(1)", "g(f(x), 1)"},
+		{"a + b |> f(c * d)", "f((a + b), (c * d))"},
+		{"x |> f(1) |> g", "g(f(x, 1))"},
+		{"x |> fn(y) { y }", "fn(y) { y; }(x)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	p := New(lexer.New("x |> f(_, _)"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "only one placeholder `_` is allowed in a pipe" {
+		t.Errorf("expected placeholder error. got=%v", errors)
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	// Pairs keep their source order
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if literal.Value != expected[i].key {
+			t.Errorf("pairs[%d] has wrong key. want=%q, got=%q", i, expected[i].key, literal.Value)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestEmptyHashAndEmptyBlock(t *testing.T) {
+	testEmptyHash := func(exp ast.Expression) {
+		t.Helper()
+		hash, ok := exp.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("exp is not ast.HashLiteral. got=%T", exp)
+		}
+		if len(hash.Pairs) != 0 {
+			t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+		}
+	}
+	parse := func(input string) *ast.Program {
+		t.Helper()
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		return program
+	}
+
+	// In expression position `{}` is always a hash
+	program := parse("let x = {};")
+	testEmptyHash(program.Statements[0].(*ast.LetStatement).Value)
+
+	program = parse("{}")
+	testEmptyHash(program.Statements[0].(*ast.ExpressionStatement).Expression)
+
+	program = parse("f({}, {})")
+	call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	testEmptyHash(call.Arguments[0])
+	testEmptyHash(call.Arguments[1])
+
+	// After `if` and `fn` it's the block
+	program = parse("if (true) {}")
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if len(exp.Then.Statements) != 0 {
+		t.Errorf("empty block has statements. got=%d", len(exp.Then.Statements))
+	}
+
+	program = parse("fn() {}")
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.Body.Statements) != 0 {
+		t.Errorf("empty body has statements. got=%d", len(function.Body.Statements))
+	}
+
+	// A hash within a block is an expression statement of the block
+	program = parse("if (true) { {} } else { {} }")
+	exp = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	for _, block := range []*ast.BlockStatement{exp.Then, exp.Else} {
+		if len(block.Statements) != 1 {
+			t.Fatalf("block has wrong number of statements. got=%d", len(block.Statements))
+		}
+		testEmptyHash(block.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, 2: 10 - 8, true: 15 / 5}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	testIntegerLiteral(t, hash.Pairs[1].Key, 2)
+	testBooleanLiteral(t, hash.Pairs[2].Key, true)
+	testInfixExpression(t, hash.Pairs[0].Value, 0, "+", 1)
+	testInfixExpression(t, hash.Pairs[1].Value, 10, "-", 8)
+	testInfixExpression(t, hash.Pairs[2].Value, 15, "/", 5)
+
+	if program.String() != `{"one": (0 + 1), 2: (10 - 8), true: (15 / 5)}` {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestTernaryExpression(t *testing.T) {
+	input := "a > b ? a : b"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.TernaryExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.TernaryExpression. got=%T", stmt.Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "a", ">", "b") {
+		return
+	}
+	testIdentifier(t, exp.Then, "a")
+	testIdentifier(t, exp.Else, "b")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a == b ? 1 + 2 : 3 * 4", "((a == b) ? (1 + 2) : (3 * 4))"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+		{"a ? b ? c : d : e", "(a ? (b ? c : d) : e)"},
+		{"x |> f ? 1 : 2", "(f(x) ? 1 : 2)"},
+		{"let m = a < b ? a : b;", "let m = ((a < b) ? a : b);"},
+		{"f(a ? b : c, d)", "f((a ? b : c), d)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestParseErrorFields(t *testing.T) {
+	p := New(lexer.New("let x = 1;\nlet y 2;"))
+	p.ParseProgram()
+
+	errors := p.ParseErrors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error. got=%d %v", len(errors), p.Errors())
+	}
+
+	err := errors[0]
+	if err.Message != "expected next token to be =, got INT instead" {
+		t.Errorf("wrong message. got=%q", err.Message)
+	}
+	if err.Token.Type != token.INT || err.Token.Literal != "2" {
+		t.Errorf("wrong token. got=%s %q", err.Token.Type, err.Token.Literal)
+	}
+	if err.Line != 2 || err.Column != 7 {
+		t.Errorf("wrong position. expected=2:7, got=%d:%d", err.Line, err.Column)
+	}
+	if err.Error() != "2:7: expected next token to be =, got INT instead" {
+		t.Errorf("wrong Error(). got=%q", err.Error())
+	}
+
+	if msgs := p.Errors(); len(msgs) != 1 || msgs[0] != err.Message {
+		t.Errorf("Errors() does not match ParseErrors(). got=%v", msgs)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		errors   []string
+		expected string // The statements which did parse
+	}{
+		{"let = 5; let y = 10;", []string{"1:5: expected next token to be IDENT, got = instead"}, "let y = 10;"},
+		{"let x 5 * 2\nlet y = 10", []string{"1:7: expected next token to be =, got INT instead"}, "let y = 10;"},
+		{"let = 5 let y = 10; y", []string{"1:5: expected next token to be IDENT, got = instead"}, "let y = 10;y"},
+		{"return ); 1", []string{"1:8: no prefix parse function for ) found"}, "1"},
+		// Skipping steps over the braces of the broken statement
+		{"let = fn() { let a = 1; a }; let b = 2;", []string{"1:5: expected next token to be IDENT, got = instead"}, "let b = 2;"},
+		// Only the broken statement of the block is dropped
+		{"fn() { let = 1; 2 }; 3", []string{"1:12: expected next token to be IDENT, got = instead"}, "fn() { 2; }3"},
+		{"if (x) { let y }", []string{"1:16: expected next token to be =, got } instead"}, "if (x) { }"},
+		// Every broken statement is still reported
+		{"let = 1; let y = 2; let 3; z", []string{
+			"1:5: expected next token to be IDENT, got = instead",
+			"1:25: expected next token to be IDENT, got INT instead",
+		}, "let y = 2;z"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		var errors []string
+		for _, err := range p.ParseErrors() {
+			errors = append(errors, err.Error())
+		}
+		if strings.Join(errors, "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("%q: wrong errors.\nwant=%q\ngot=%q", tt.input, tt.errors, errors)
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("%q: wrong program. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (let i = 0; i < 10; i = i + 1) { x }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if !testLetStatements(t, stmt.Init, "i") {
+		return
+	}
+	if !testInfixExpression(t, stmt.Condition, "i", "<", 10) {
+		return
+	}
+	post, ok := stmt.Post.(*ast.ExpressionStatement).Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Post is not an ast.AssignExpression. got=%q", stmt.Post)
+	}
+	if !testInfixExpression(t, post.Value, "i", "+", 1) {
+		return
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("stmt.Body.Statements is not 1. got=%d", len(stmt.Body.Statements))
+	}
+
+	expected := "for (let i = 0; (i < 10); (i = (i + 1))) { x; }"
+	if actual := stmt.String(); actual != expected {
+		t.Errorf("expected=%q, got=%q", expected, actual)
+	}
+}
+
+func TestForStatementEmptyClauses(t *testing.T) {
+	p := New(lexer.New("for (;;) { }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Init != nil || stmt.Condition != nil || stmt.Post != nil {
+		t.Errorf("expected empty clauses. got=%q", stmt.String())
+	}
+
+	p = New(lexer.New("for (let i = 0 i < 1; ) { }"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "expected next token to be ;, got IDENT instead" {
+		t.Errorf("expected missing semicolon error. got=%v", errors)
+	}
+}
+
+func TestForStatementTrailingSemicolon(t *testing.T) {
+	tests := []struct {
+		input      string
+		statements int
+	}{
+		{"for (;false;) {}; 1", 2},
+		{"let x = 0; for (; x < 3;) { x++ }; x", 3},
+		{"for (;;) { break };", 1},
+		{"fn() { for (;;) { break }; 2 }", 1},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != tt.statements {
+			t.Errorf("%q: expected %d statements. got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+	}
+}
+
+func TestBreakAndContinueStatements(t *testing.T) {
+	p := New(lexer.New("for (;;) { break; continue }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	body := program.Statements[0].(*ast.ForStatement).Body
+	if len(body.Statements) != 2 {
+		t.Fatalf("body does not contain 2 statements. got=%d", len(body.Statements))
+	}
+	if _, ok := body.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("body.Statements[0] is not ast.BreakStatement. got=%T", body.Statements[0])
+	}
+	if _, ok := body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[1] is not ast.ContinueStatement. got=%T", body.Statements[1])
+	}
+}
+
+func TestAssignExpressionAssociativity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a = b = 5", "(a = (b = 5))"},
+		{"a = b = c = 1 + 2", "(a = (b = (c = (1 + 2))))"},
+		{"a = x ? 1 : 2", "(a = (x ? 1 : 2))"},
+		{"a = x |> f", "(a = f(x))"},
+		{"f(a = 1)", "f((a = 1))"},
+		{"(a = 1) + 2", "((a = 1) + 2)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	p := New(lexer.New("1 = 2"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "cannot assign to 1" {
+		t.Errorf("expected invalid target error. got=%v", errors)
+	}
+
+	targets := []struct {
+		input    string
+		expected string
+	}{
+		{`"s" = 1`, `cannot assign to "s"`},
+		{"[1] = 2", "cannot assign to an array"},
+		{"f(x) = 2", "cannot assign to a call"},
+		{"-x = 3", "cannot assign to an expression"},
+	}
+	for _, tt := range targets {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) == 0 || errs[0] != tt.expected {
+			t.Errorf("%q: expected error %q. got=%v", tt.input, tt.expected, errs)
+		}
+	}
+
+	// Targets broken by an earlier error must be reported, not printed
+	for _, input := range []string{"(] = 1", "f(,) = 2", "! ! const =", "[1, ,] = 3"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parser errors from ParseProgram", input)
+		}
+		if _, errs := ParseExpression(input); len(errs) == 0 {
+			t.Errorf("%q: expected parser errors from ParseExpression", input)
+		}
+	}
+}
+
+func TestCompoundAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x += 1", "(x = (x + 1))"},
+		{"x -= y * 2", "(x = (x - (y * 2)))"},
+		{"x *= 3", "(x = (x * 3))"},
+		{"x /= 4", "(x = (x / 4))"},
+		{"x += y += 1", "(x = (x + (y = (y + 1))))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	p := New(lexer.New("f() += 1"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "cannot assign to a call" {
+		t.Errorf("expected invalid target error. got=%v", errors)
+	}
+
+	// Targets broken by an earlier error must be reported, not printed
+	for _, input := range []string{"(] += 1", "f(,) -= 2", "! ! const *="} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parser errors from ParseProgram", input)
+		}
+		if _, errs := ParseExpression(input); len(errs) == 0 {
+			t.Errorf("%q: expected parser errors from ParseExpression", input)
+		}
+	}
+}
+
+func TestIndexAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`h["a"] = 1`, `((h["a"]) = 1)`},
+		{"xs[i + 1] = y * 2", "((xs[(i + 1)]) = (y * 2))"},
+		{"m[0][1] = 5", "(((m[0])[1]) = 5)"},
+		{"a[0] = b[1] = 2", "((a[0]) = ((b[1]) = 2))"},
+		{"x = a[0] = 1", "(x = ((a[0]) = 1))"},
+		{`h["n"] += 1`, `((h["n"]) = ((h["n"]) + 1))`},
+		{"xs[0] *= 3", "((xs[0]) = ((xs[0]) * 3))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	program := New(lexer.New("h[k] = v")).ParseProgram()
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexAssignExpression)
+	if !ok {
+		t.Fatalf("exp is not *ast.IndexAssignExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	testIdentifier(t, exp.Target.Left, "h")
+	testIdentifier(t, exp.Target.Index, "k")
+	testIdentifier(t, exp.Value, "v")
+
+	// A compound assignment keeps its operator rather than reading the target again
+	program = New(lexer.New("h[k] -= v")).ParseProgram()
+	exp, ok = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexAssignExpression)
+	if !ok {
+		t.Fatalf("exp is not *ast.IndexAssignExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if exp.Operator != "-" {
+		t.Errorf("exp.Operator is not %q. got=%q", "-", exp.Operator)
+	}
+	testIdentifier(t, exp.Value, "v")
+
+	p := New(lexer.New("f()[0] = 1; 1 = 2"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "cannot assign to 1" {
+		t.Errorf("expected only the literal target to be rejected. got=%v", errors)
+	}
+}
+
+func TestUpdateExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"++i", "(++i)"},
+		{"--i", "(--i)"},
+		{"i++", "(i++)"},
+		{"i--", "(i--)"},
+		{"++i * 2", "((++i) * 2)"},
+		{"i++ * 2", "((i++) * 2)"},
+		{"-i--", "(-(i--))"},
+		{"for (let i = 0; i < 3; i++) { }", "for (let i = 0; (i < 3); (i++)) { }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"++5", "expected next token to be IDENT, got INT instead"},
+		{"f()++", "cannot apply ++ to a call"},
+		{"1--", "cannot apply -- to 1"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) == 0 || errs[0] != tt.expected {
+			t.Errorf("expected error %q. got=%v", tt.expected, errs)
+		}
+	}
+
+	// Targets broken by an earlier error must be reported, not printed
+	for _, input := range []string{"-] ++", "! && --", "f(,)++"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parser errors from ParseProgram", input)
+		}
+		if _, errs := ParseExpression(input); len(errs) == 0 {
+			t.Errorf("%q: expected parser errors from ParseExpression", input)
+		}
+	}
+}
+
+func TestNullLiteral(t *testing.T) {
+	p := New(lexer.New("null;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	null, ok := stmt.Expression.(*ast.NullLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.NullLiteral. got=%T", stmt.Expression)
+	}
+	if null.TokenLiteral() != "null" {
+		t.Errorf("null.TokenLiteral not %q. got=%q", "null", null.TokenLiteral())
+	}
+}
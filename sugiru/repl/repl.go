@@ -4,18 +4,99 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
+	"strings"
 	"sugiru/evaluator"
 	"sugiru/lexer"
+	"sugiru/object"
 	"sugiru/parser"
+	"sugiru/token"
 )
 
 const PROMPT = ">> "
 
+// ANSI escape codes, used for the errors when color is on
+const (
+	colorRed   = "\x1b[31m"
+	colorReset = "\x1b[0m"
+)
+
+// Config customizes how the REPL presents itself
+type Config struct {
+	Prompt string // Written before reading each input, unused in indexed mode
+	Banner string // Written once when the REPL starts, if not empty
+
+	// Prompts are numbered `In[n]: ` and results are printed as `Out[n]: value`.
+	// Every result is kept in a history which the session can read back with
+	// the `Out(n)` builtin.
+	Indexed bool
+
+	// Builtins such as `eval`, which reach past the code entered, are hidden
+	Sandboxed bool
+}
+
+// Start starts the REPL with the default prompt
 func Start(in io.Reader, out io.Writer) {
+	StartWithConfig(in, out, Config{Prompt: PROMPT})
+}
+
+// StartIndexed starts the REPL in indexed mode
+func StartIndexed(in io.Reader, out io.Writer) {
+	StartWithConfig(in, out, Config{Indexed: true})
+}
+
+// The modes decide what the REPL does with each input, switched with `:mode <name>`
+const (
+	modeEval = "eval" // Evaluate the input and print the result ( default )
+	modeAST  = "ast"  // Print the canonical string of the parsed program
+	modeLex  = "lex"  // Print every token of the input
+)
+
+// session is the state kept between the inputs of a single REPL run
+type session struct {
+	out     io.Writer
+	env     *object.Environment
+	outer   *object.Environment // Bindings provided by the REPL, such as `_`
+	mode    string
+	indexed bool
+	sandbox bool // Every environment of the session is sandboxed
+	silent  bool // Results aren't echoed, errors still are
+	color   bool // Errors are printed in red, on by default when out is a terminal
+
+	// Results of previous inputs, keyed by their input index
+	history map[int64]object.Object
+	index   int64
+
+	// Every line entered, except for commands, recalled with `!n`
+	inputs []string
+}
+
+// StartWithConfig starts the REPL, everything it writes goes to out
+func StartWithConfig(in io.Reader, out io.Writer, config Config) {
 	scanner := bufio.NewScanner(in)
+	s := &session{
+		out:     out,
+		mode:    modeEval,
+		indexed: config.Indexed,
+		sandbox: config.Sandboxed,
+		history: map[int64]object.Object{},
+		index:   1,
+		color:   isTerminal(out),
+	}
+
+	if config.Banner != "" {
+		io.WriteString(out, config.Banner)
+	}
+
+	s.resetEnv()
 
 	for {
-		fmt.Printf(PROMPT)
+		if s.indexed {
+			fmt.Fprintf(out, "In[%d]: ", s.index)
+		} else {
+			io.WriteString(out, config.Prompt)
+		}
 		scanned := scanner.Scan()
 
 		// If nothing is scanned, we simply end
@@ -25,26 +106,285 @@ func Start(in io.Reader, out io.Writer) {
 
 		// Retrieve the text scanned
 		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-
-		program := p.ParseProgram()
-		if len(p.Errors()) > 0 {
-			printParseErrors(out, p.Errors())
+		if strings.HasPrefix(line, ":") {
+			if quit := s.runCommand(line); quit {
+				return
+			}
 			continue
 		}
 
-		evaluated := evaluator.Eval(program)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		// `!n` runs input n again, printing it first so it's clear what ran
+		if n, ok := historyRef(line); ok {
+			if n < 1 || n > len(s.inputs) {
+				fmt.Fprintf(s.out, "no input at index %d\n", n)
+				continue
+			}
+			line = s.inputs[n-1]
+			io.WriteString(s.out, line+"\n")
+		}
+		s.inputs = append(s.inputs, line)
+
+		switch s.mode {
+		case modeLex:
+			s.lex(line)
+		case modeAST:
+			s.printAST(line)
+		default:
+			s.eval(line)
 		}
 	}
 }
 
-func printParseErrors(out io.Writer, errors []string) {
-	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// runCommand handles the REPL commands, which are inputs starting
+// with `:`, returning true when the REPL should stop
+func (s *session) runCommand(line string) bool {
+	fields := strings.Fields(line)
+
+	// Everything after the command itself, for commands taking source
+	rest := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+
+	switch fields[0] {
+	case ":quit", ":exit":
+		io.WriteString(s.out, "Goodbye!\n")
+		return true
+	case ":ast":
+		// Print the AST of the rest of the line, whatever the mode
+		s.printAST(rest)
+	case ":tokens":
+		s.lex(rest)
+	case ":env":
+		// List the top level bindings, builtins aren't included
+		for _, name := range s.env.Names() {
+			val, _ := s.env.Get(name)
+			fmt.Fprintf(s.out, "%s = %s\n", name, val.Inspect())
+		}
+	case ":load":
+		// The rest of the line is the path, which may hold spaces
+		if rest == "" {
+			io.WriteString(s.out, "usage: :load <file>\n")
+			return false
+		}
+		s.load(rest)
+	case ":reset":
+		s.resetEnv()
+		io.WriteString(s.out, "Environment reset\n")
+	case ":history":
+		for i, input := range s.inputs {
+			fmt.Fprintf(s.out, "%d  %s\n", i+1, input)
+		}
+	case ":color":
+		if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+			io.WriteString(s.out, "usage: :color <on|off>\n")
+			return false
+		}
+		s.color = fields[1] == "on"
+	case ":silent":
+		if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+			io.WriteString(s.out, "usage: :silent <on|off>\n")
+			return false
+		}
+		s.silent = fields[1] == "on"
+	case ":mode":
+		if len(fields) != 2 {
+			fmt.Fprintf(s.out, "usage: :mode <%s|%s|%s>\n", modeLex, modeAST, modeEval)
+			return false
+		}
+		switch fields[1] {
+		case modeLex, modeAST, modeEval:
+			s.mode = fields[1]
+		default:
+			fmt.Fprintf(s.out, "unknown mode: %s, want one of %s, %s or %s\n",
+				fields[1], modeLex, modeAST, modeEval)
+		}
+	default:
+		fmt.Fprintf(s.out, "unknown command: %s\n", fields[0])
+	}
+
+	return false
+}
+
+// historyRef returns n when the line is a `!n` history reference,
+// anything else after the `!` is left to the parser as a bang
+func historyRef(line string) (int, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "!") {
+		return 0, false
+	}
+
+	digits := line[1:]
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(digits)
+	return n, err == nil
+}
+
+// resetEnv gives the session fresh environments, dropping every binding
+// including `_`, the builtins are untouched as they aren't bound in them.
+// The REPL's bindings live in a scope of their own so :env only lists the user's bindings.
+func (s *session) resetEnv() {
+	if s.sandbox {
+		s.outer = object.NewSandboxedEnvironment()
+	} else {
+		s.outer = object.NewEnvironment()
+	}
+	s.env = object.NewEnclosedEnvironment(s.outer)
+
+	if s.indexed {
+		s.outer.Set("Out", outBuiltin(s.history))
+	}
+}
+
+// load evaluates the file in the session's environment, so what it defines
+// can be used afterwards. Like running a file only the errors are printed.
+func (s *session) load(path string) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	p := parser.New(lexer.New(string(source)))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		s.printParseErrors(string(source), p)
+		return
+	}
+
+	if evaluated := evaluator.Eval(program, s.env); evaluated != nil && evaluated.Type() == object.ERROR_OBJ {
+		io.WriteString(s.out, s.paint(evaluated.Inspect()))
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// lex prints every token of the line, one per line. The reason is only
+// shown for the illegal strings which have one
+func (s *session) lex(line string) {
+	l := lexer.New(line)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "{Type:%s Literal:%s Line:%d Column:%d", tok.Type, tok.Literal, tok.Line, tok.Column)
+		if tok.Reason != "" {
+			fmt.Fprintf(s.out, " Reason:%s", tok.Reason)
+		}
+		fmt.Fprintln(s.out, "}")
+	}
+}
+
+// printAST prints how the line parses
+func (s *session) printAST(line string) {
+	p := parser.New(lexer.New(line))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		s.printParseErrors(line, p)
+		return
+	}
+
+	io.WriteString(s.out, program.String())
+	io.WriteString(s.out, "\n")
+}
+
+// eval evaluates the line in the session's environment, printing the result
+func (s *session) eval(line string) {
+	p := parser.New(lexer.New(line))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		s.printParseErrors(line, p)
+		return
+	}
+
+	evaluated := evaluator.Eval(program, s.env)
+	if evaluated != nil {
+		// `_` holds the last result, errors and null don't replace it
+		if evaluated.Type() != object.ERROR_OBJ && evaluated.Type() != object.NULL_OBJ {
+			s.outer.Set(evaluator.DISCARD, evaluated)
+		}
+
+		if s.indexed {
+			s.history[s.index] = evaluated
+		}
+
+		// Errors are echoed even when silent
+		if !s.silent || evaluated.Type() == object.ERROR_OBJ {
+			if s.indexed {
+				fmt.Fprintf(s.out, "Out[%d]: ", s.index)
+			}
+			if evaluated.Type() == object.ERROR_OBJ {
+				io.WriteString(s.out, s.paint(evaluated.Inspect()))
+			} else {
+				io.WriteString(s.out, evaluated.Inspect())
+			}
+			io.WriteString(s.out, "\n")
+		}
+	}
+
+	s.index++
+}
+
+// outBuiltin creates the `Out(n)` builtin, returning the result of input n
+func outBuiltin(history map[int64]object.Object) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+			}
+
+			n, ok := args[0].(*object.Integer)
+			if !ok {
+				return &object.Error{Message: fmt.Sprintf("argument to `Out` must be INTEGER, got %s", args[0].Type())}
+			}
+
+			result, ok := history[n.Value]
+			if !ok {
+				return &object.Error{Message: fmt.Sprintf("no output at index %d", n.Value)}
+			}
+
+			return result
+		},
+	}
+}
+
+// paint colors the error text red when color is on
+func (s *session) paint(text string) string {
+	if !s.color {
+		return text
+	}
+	return colorRed + text + colorReset
+}
+
+// isTerminal reports whether the output is written to a terminal, anything
+// else such as a file or a pipe shouldn't get escape codes
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (s *session) printParseErrors(source string, p *parser.Parser) {
+	out := s.out
+
+	io.WriteString(out, s.paint(" parser errors:")+"\n")
+	for _, err := range p.ParseErrors() {
+		io.WriteString(out, "\t"+s.paint(err.Message)+"\n")
+
+		// Point at where the error happened
+		caret := parser.RenderCaret(source, err.Line, err.Column)
+		if caret == "" {
+			continue
+		}
+		for _, l := range strings.Split(caret, "\n") {
+			io.WriteString(out, "\t"+l+"\n")
+		}
 	}
 }
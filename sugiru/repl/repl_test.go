@@ -0,0 +1,422 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexedMode(t *testing.T) {
+	input := strings.Join([]string{
+		"1 + 2",
+		"let x = 10",
+		`substr("hello", 0, 2)`,
+		"Out(1) * 2",
+		"Out(3)",
+		"Out(2)",
+	}, "\n")
+
+	var out bytes.Buffer
+	StartIndexed(strings.NewReader(input), &out)
+
+	expected := "In[1]: Out[1]: 3\n" +
+		"In[2]: " +
+		"In[3]: Out[3]: \"he\"\n" +
+		"In[4]: Out[4]: 6\n" +
+		"In[5]: Out[5]: \"he\"\n" +
+		"In[6]: Out[6]: ERROR: no output at index 2\n" +
+		"In[7]: "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestParseErrorCaret(t *testing.T) {
+	var out bytes.Buffer
+	StartIndexed(strings.NewReader("let x 5"), &out)
+
+	expected := "In[1]:  parser errors:\n" +
+		"\texpected next token to be =, got INT instead\n" +
+		"\tlet x 5\n" +
+		"\t      ^\n" +
+		"In[1]: "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestModes(t *testing.T) {
+	input := strings.Join([]string{
+		"let x = 1 + 2",
+		":mode ast",
+		"let x = 1 + 2",
+		":mode lex",
+		"let x = 1 + 2",
+		":mode eval",
+		"x * 2",
+		":mode wrong",
+		":nope",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> >> >> let x = (1 + 2);\n" +
+		">> >> {Type:LET Literal:let Line:1 Column:1}\n" +
+		"{Type:IDENT Literal:x Line:1 Column:5}\n" +
+		"{Type:= Literal:= Line:1 Column:7}\n" +
+		"{Type:INT Literal:1 Line:1 Column:9}\n" +
+		"{Type:+ Literal:+ Line:1 Column:11}\n" +
+		"{Type:INT Literal:2 Line:1 Column:13}\n" +
+		">> >> 6\n" +
+		">> unknown mode: wrong, want one of lex, ast or eval\n" +
+		">> unknown command: :nope\n" +
+		">> "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestAstAndTokensCommands(t *testing.T) {
+	input := strings.Join([]string{
+		":ast let x = 5 * -2;",
+		":tokens x + 1",
+		":ast let x 5",
+		// Neither command evaluates its input
+		"x",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> let x = (5 * (-2));\n" +
+		">> {Type:IDENT Literal:x Line:1 Column:1}\n" +
+		"{Type:+ Literal:+ Line:1 Column:3}\n" +
+		"{Type:INT Literal:1 Line:1 Column:5}\n" +
+		">>  parser errors:\n" +
+		"\texpected next token to be =, got INT instead\n" +
+		"\tlet x 5\n" +
+		"\t      ^\n" +
+		">> ERROR: identifier not found: x\n" +
+		">> "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestEnvCommand(t *testing.T) {
+	input := strings.Join([]string{
+		":env",
+		`let name = "sugiru"`,
+		"let count = 2",
+		"let f = fn() { let inner = 1; inner }",
+		":env",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> >> >> >> >> count = 2\n" +
+		"f = fn f() { let inner = 1; inner; }\n" +
+		"name = \"sugiru\"\n" +
+		">> "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+
+	// The Out builtin of indexed mode isn't listed
+	out.Reset()
+	StartIndexed(strings.NewReader("let x = 1\n:env"), &out)
+
+	expected = "In[1]: In[2]: x = 1\nIn[2]: "
+	if out.String() != expected {
+		t.Errorf("wrong indexed output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestQuitCommands(t *testing.T) {
+	for _, cmd := range []string{":quit", ":exit"} {
+		var out bytes.Buffer
+		StartIndexed(strings.NewReader("1 + 1\n"+cmd+"\n2 + 2"), &out)
+
+		// Nothing after the command is evaluated
+		expected := "In[1]: Out[1]: 2\nIn[2]: Goodbye!\n"
+		if out.String() != expected {
+			t.Errorf("%s: wrong output.\nexpected=%q\ngot=%q", cmd, expected, out.String())
+		}
+	}
+}
+
+func TestSilentCommand(t *testing.T) {
+	input := strings.Join([]string{
+		":silent on",
+		"1 + 1",
+		"missing",
+		":silent maybe",
+		":silent off",
+		"2 + 2",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> >> >> ERROR: identifier not found: missing\n" +
+		">> usage: :silent <on|off>\n" +
+		">> >> 4\n" +
+		">> "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+
+	// Silenced results are still kept in the history
+	out.Reset()
+	StartIndexed(strings.NewReader(":silent on\n21 * 2\n:silent off\nOut(1)"), &out)
+
+	expected = "In[1]: In[1]: In[2]: In[2]: Out[2]: 42\nIn[3]: "
+	if out.String() != expected {
+		t.Errorf("wrong indexed output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestConfig(t *testing.T) {
+	var out bytes.Buffer
+	StartWithConfig(strings.NewReader("1 + 2\n:quit"), &out, Config{
+		Prompt: "sugiru> ",
+		Banner: "welcome\n",
+	})
+
+	// The banner comes first, then a prompt before every input
+	expected := "welcome\nsugiru> 3\nsugiru> Goodbye!\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+
+	// The prompt is replaced by the numbered one in indexed mode
+	out.Reset()
+	StartWithConfig(strings.NewReader("1 + 2"), &out, Config{Prompt: "sugiru> ", Indexed: true})
+
+	expected = "In[1]: Out[1]: 3\nIn[2]: "
+	if out.String() != expected {
+		t.Errorf("wrong indexed output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+
+	// Sandboxing lasts through a reset
+	out.Reset()
+	StartWithConfig(strings.NewReader("eval(\"1\")\n:reset\neval(\"1\")"), &out, Config{Indexed: true, Sandboxed: true})
+
+	expected = "In[1]: Out[1]: ERROR: identifier not found: eval\n" +
+		"In[2]: Environment reset\n" +
+		"In[2]: Out[2]: ERROR: identifier not found: eval\n" +
+		"In[3]: "
+	if out.String() != expected {
+		t.Errorf("wrong sandboxed output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	input := strings.Join([]string{
+		"let x = 2",
+		"x * 10",
+		":env",
+		"!2",
+		"!9",
+		"!true",
+		":history",
+	}, "\n")
+
+	var out bytes.Buffer
+	StartIndexed(strings.NewReader(input), &out)
+
+	// Commands aren't recorded, recalled inputs are recorded as what they ran
+	expected := "In[1]: " +
+		"In[2]: Out[2]: 20\n" +
+		"In[3]: x = 2\n" +
+		"In[3]: x * 10\nOut[3]: 20\n" +
+		"In[4]: no input at index 9\n" +
+		"In[4]: Out[4]: false\n" +
+		"In[5]: 1  let x = 2\n2  x * 10\n3  x * 10\n4  !true\n" +
+		"In[5]: "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestHistoryRef(t *testing.T) {
+	tests := []struct {
+		line string
+		n    int
+		ok   bool
+	}{
+		{"!1", 1, true},
+		{" !12 ", 12, true},
+		{"!0", 0, true},
+		{"!", 0, false},
+		{"!x", 0, false},
+		{"!-1", 0, false},
+		{"!1 + 2", 0, false},
+		{"1", 0, false},
+	}
+
+	for _, tt := range tests {
+		n, ok := historyRef(tt.line)
+		if n != tt.n || ok != tt.ok {
+			t.Errorf("historyRef(%q) wrong. want=(%d, %t), got=(%d, %t)", tt.line, tt.n, tt.ok, n, ok)
+		}
+	}
+}
+
+func TestLastResult(t *testing.T) {
+	input := strings.Join([]string{
+		"_",
+		"1 + 2",
+		"_ * 10",
+		"missing",
+		"null",
+		"_ + 1",
+		// Binding `_` still discards
+		"let _ = 100",
+		"_",
+		":env",
+	}, "\n")
+
+	var out bytes.Buffer
+	StartIndexed(strings.NewReader(input), &out)
+
+	expected := "In[1]: Out[1]: ERROR: '_' is not readable\n" +
+		"In[2]: Out[2]: 3\n" +
+		"In[3]: Out[3]: 30\n" +
+		"In[4]: Out[4]: ERROR: identifier not found: missing\n" +
+		"In[5]: Out[5]: null\n" +
+		"In[6]: Out[6]: 31\n" +
+		"In[7]: In[8]: Out[8]: 31\n" +
+		"In[9]: In[9]: "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, source string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	lib := write("lib.sg", "let double = fn(x) { x * 2 };\nlet ten = double(5);\n")
+	broken := write("broken.sg", "let x 5;")
+	failing := write("failing.sg", "let y = 1;\nmissing;\nlet z = 2;")
+
+	input := strings.Join([]string{
+		":load " + lib,
+		"double(ten)",
+		":load " + broken,
+		":load " + failing,
+		// What ran before the error is kept
+		"y",
+		"z",
+		":load",
+		":load " + filepath.Join(dir, "nope.sg"),
+	}, "\n")
+
+	var out bytes.Buffer
+	StartWithConfig(strings.NewReader(input), &out, Config{})
+
+	expected := "20\n" +
+		" parser errors:\n" +
+		"\texpected next token to be =, got INT instead\n" +
+		"\tlet x 5;\n" +
+		"\t      ^\n" +
+		"ERROR: identifier not found: missing\n" +
+		"1\n" +
+		"ERROR: identifier not found: z\n" +
+		"usage: :load <file>\n" +
+		"open " + filepath.Join(dir, "nope.sg") + ": no such file or directory\n"
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestResetCommand(t *testing.T) {
+	input := strings.Join([]string{
+		"let x = 5",
+		"const c = 1",
+		"x + 1",
+		":reset",
+		"x",
+		"_",
+		":env",
+		// Constants can be declared again and builtins are still there
+		"const c = 2",
+		"abs(-2)",
+		"Out(3)",
+	}, "\n")
+
+	var out bytes.Buffer
+	StartIndexed(strings.NewReader(input), &out)
+
+	expected := "In[1]: In[2]: In[3]: Out[3]: 6\n" +
+		"In[4]: Environment reset\n" +
+		"In[4]: Out[4]: ERROR: identifier not found: x\n" +
+		"In[5]: Out[5]: ERROR: '_' is not readable\n" +
+		"In[6]: In[6]: In[7]: Out[7]: 2\n" +
+		"In[8]: Out[8]: 6\n" +
+		"In[9]: "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestColorCommand(t *testing.T) {
+	errors := []string{"let = 1", "missing", ":load " + filepath.Join(t.TempDir(), "none.sg")}
+
+	// A buffer isn't a terminal, so nothing is colored by default
+	var out bytes.Buffer
+	Start(strings.NewReader(strings.Join(append(errors, "1 + 1"), "\n")), &out)
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("escape codes written to a non-terminal. got=%q", out.String())
+	}
+
+	input := strings.Join([]string{
+		":color on",
+		"missing",
+		"let = 1",
+		"1 + 1",
+		":color off",
+		"missing",
+		":color",
+		":color maybe",
+	}, "\n")
+
+	out.Reset()
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> >> \x1b[31mERROR: identifier not found: missing\x1b[0m\n" +
+		">> \x1b[31m parser errors:\x1b[0m\n" +
+		"\t\x1b[31mexpected next token to be IDENT, got = instead\x1b[0m\n" +
+		"\tlet = 1\n" +
+		"\t    ^\n" +
+		">> 2\n" +
+		">> >> ERROR: identifier not found: missing\n" +
+		">> usage: :color <on|off>\n" +
+		">> usage: :color <on|off>\n" +
+		">> "
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
@@ -5,15 +5,21 @@ type TokenType string
 type Token struct {
 	Type    TokenType // The type
 	Literal string    // The raw text value
+	Line    int       // The line the token starts on ( 1-based )
+	Column  int       // The column the token starts on ( 1-based )
+	Reason  string    // Why an ILLEGAL string was rejected, empty for a stray character
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // Only produced when the lexer keeps comments
 
 	// Identifiers
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	FLOAT  = "FLOAT"
+	STRING = "STRING"
 
 	// Operators
 	ASSIGN   = "="
@@ -22,40 +28,71 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POW      = "**"
+	INCR     = "++"
+	DECR     = "--"
+
+	// Compound assignments
+	PLUS_EQ     = "+="
+	MINUS_EQ    = "-="
+	ASTERISK_EQ = "*="
+	SLASH_EQ    = "/="
 
 	LT = "<"
 	GT = ">"
 
 	// Delimiters
 	COMMA     = ","
+	ELLIPSIS  = "..."
 	SEMICOLON = ";"
+	COLON     = ":"
+	QUESTION  = "?"
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	FOR      = "FOR"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 
 	EQ     = "=="
 	NOT_EQ = "!="
+
+	PIPE = "|>"
+
+	// Logical operators, short-circuiting
+	AND = "&&"
+	OR  = "||"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"null":     NULL,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"for":      FOR,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 // LookupIdent returns the token type for the given identifier